@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"snoozybot/internal/config"
+	"snoozybot/internal/database"
+)
+
+const usage = `usage:
+  snoozybot config schema                             print the JSON Schema of the guild config
+  snoozybot config docs                               print the Markdown reference of the guild config keys
+  snoozybot config export <guild>                     print the config of a guild as YAML
+  snoozybot config import <guild> <file> [--apply]    show the changes a YAML file would make, and make them with --apply`
+
+// Runs a command given on the command line instead of the bot, and returns the exit code
+func runCommand(args []string) int {
+	if err := runConfigCommand(args); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+func runConfigCommand(args []string) error {
+	apply := slices.Contains(args, "--apply")
+	args = slices.DeleteFunc(args, func(arg string) bool { return arg == "--apply" })
+	switch {
+	case len(args) == 2 && args[0] == "config" && args[1] == "schema":
+		schema, err := config.JSONSchema()
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(schema))
+	case len(args) == 2 && args[0] == "config" && args[1] == "docs":
+		fmt.Print(config.Markdown())
+	case len(args) == 3 && args[0] == "config" && args[1] == "export":
+		if err := database.Connect(); err != nil {
+			return err
+		}
+		document, err := config.Export(args[2])
+		if err != nil {
+			return err
+		}
+		os.Stdout.Write(document)
+	case len(args) == 4 && args[0] == "config" && args[1] == "import":
+		if err := database.Connect(); err != nil {
+			return err
+		}
+		document, err := os.ReadFile(args[3])
+		if err != nil {
+			return err
+		}
+		changes, err := config.PlanImport(args[2], document)
+		if err != nil {
+			return err
+		}
+		fmt.Print(config.FormatDiff(changes))
+		if !apply {
+			fmt.Printf("%d changes. Run again with --apply to make them.\n", len(changes))
+			return nil
+		}
+		// channel and role IDs can't be checked without a bot session, unlike with /admin config import
+		if err := config.ApplyImport(args[2], changes, "cli"); err != nil {
+			return err
+		}
+		fmt.Printf("Made %d changes.\n", len(changes))
+	default:
+		return fmt.Errorf("%s", usage)
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+package background
+
+import (
+	"context"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/samber/lo"
+)
+
+// Once the shutdown deadline passes and the context is cancelled, work gets this long to return before it is
+// abandoned.
+const CancelGracePeriod = 2 * time.Second
+
+var (
+	globalCtx, cancelGlobalCtx = context.WithCancel(context.Background())
+
+	mu       sync.Mutex
+	stopping bool
+	running  = make(map[string]int) // name -> number of running goroutines or handlers
+	total    int
+	idle     = make(chan struct{}) // closed once shutdown has started and nothing is running
+	isIdle   bool
+)
+
+// Context cancelled when the shutdown deadline passes. Long running work should stop when it is done.
+func Context() context.Context {
+	return globalCtx
+}
+
+// Marks the start of a handler that shutdown waits for. The returned function must be called when the handler
+// returns. Returns false if shutdown has started, in which case the handler should not run.
+func Track(name string) (func(), bool) {
+	mu.Lock()
+	defer mu.Unlock()
+	if stopping {
+		log.Debug().Str("name", name).Msg("Shutting down. Not handling new work.")
+		return nil, false
+	}
+	return start(name), true
+}
+
+// Runs fn in a goroutine that shutdown waits for. Unlike Track this is allowed during shutdown, so that work already
+// in progress (e.g. due reminders taken from the database) is not dropped. Panics are logged and do not crash the bot.
+func Go(name string, fn func(ctx context.Context)) {
+	mu.Lock()
+	done := start(name)
+	mu.Unlock()
+	go func() {
+		defer done()
+		defer func() {
+			if rec := recover(); rec != nil {
+				log.Error().Str("name", name).Any("panic", rec).Bytes("stack", debug.Stack()).Msg("Captured panic in background goroutine")
+			}
+		}()
+		fn(globalCtx)
+	}()
+}
+
+// Must be called with mu held
+func start(name string) func() {
+	total++
+	running[name]++
+	return sync.OnceFunc(func() {
+		mu.Lock()
+		defer mu.Unlock()
+		total--
+		running[name]--
+		if running[name] == 0 {
+			delete(running, name)
+		}
+		checkIdle()
+	})
+}
+
+// Must be called with mu held
+func checkIdle() {
+	if stopping && total == 0 && !isIdle {
+		isIdle = true
+		close(idle)
+	}
+}
+
+// Stops accepting new work and waits for running work to finish. If it does not finish before the timeout, the
+// context is cancelled and work gets a short grace period to return. Returns false if some work was abandoned.
+func Shutdown(timeout time.Duration) bool {
+	mu.Lock()
+	stopping = true
+	log.Info().Any("running", running).Dur("timeout", timeout).Msg("Waiting for running work to finish")
+	checkIdle()
+	mu.Unlock()
+
+	defer cancelGlobalCtx()
+
+	select {
+	case <-idle:
+		return true
+	case <-time.After(timeout):
+	}
+	mu.Lock()
+	log.Warn().Any("running", running).Msg("Shutdown deadline passed. Cancelling running work.")
+	mu.Unlock()
+	cancelGlobalCtx()
+
+	select {
+	case <-idle:
+		return true
+	case <-time.After(CancelGracePeriod):
+		mu.Lock()
+		defer mu.Unlock()
+		log.Error().Strs("running", lo.Keys(running)).Msg("Work did not stop after cancellation. Abandoning it.")
+		return false
+	}
+}
+
+// Like lo.AttemptWithDelay, but stops waiting between attempts when ctx is cancelled.
+func Retry(ctx context.Context, maxAttempts int, delay time.Duration, fn func(index int) error) error {
+	var err error
+	for index := range maxAttempts {
+		if err = fn(index); err == nil {
+			return nil
+		}
+		if index == maxAttempts-1 {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+	return err
+}
@@ -1,120 +1,421 @@
 package bot
 
 import (
+	"fmt"
+	"maps"
 	"runtime/debug"
+	"slices"
+	"snoozybot/internal/background"
 	"snoozybot/internal/commands"
 	"snoozybot/internal/config"
+	"snoozybot/internal/errorreport"
 	"snoozybot/internal/events"
+	"snoozybot/internal/features"
+	"snoozybot/internal/metrics"
+	"strconv"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	dg "github.com/bwmarrin/discordgo"
+	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 	"github.com/samber/lo"
 )
 
+// How often the bot manager checks the database for added, removed or reassigned discord tokens.
+const reloadInterval = 10 * time.Minute
+
+// Discord allows max_concurrency shards to identify per this window
+const identifyWindow = 5 * time.Second
+
 type BotManager struct {
-	bots      []*dg.Session
-	GuildBots map[string]*dg.Session
-	stop      chan struct{}
-	wg        sync.WaitGroup
-	ready     sync.WaitGroup
+	mu           sync.RWMutex
+	reloadMu     sync.Mutex          // one reload at a time, since reloads run without holding mu while they call discord
+	bots         map[string]*botUser // token -> bot
+	guildBots    map[string]*shard   // guild -> shard of the bot for that guild
+	requirements []IntentRequirement
+	started      bool        // sessions have been opened; bots added by reload are started immediately
+	ready        atomic.Bool // Start has returned
+	stop         chan struct{}
+	wg           sync.WaitGroup
 }
 
-func CreateBotManager() *BotManager {
-	bm := &BotManager{bots: []*dg.Session{}, GuildBots: make(map[string]*dg.Session), stop: make(chan struct{}), wg: sync.WaitGroup{}}
+// A single bot user, along with the guilds it is currently responsible for. Each bot user has one gateway session per shard.
+type botUser struct {
+	mu             sync.RWMutex
+	guilds         []string
+	shards         []*shard
+	maxConcurrency int
+	intents        dg.Intent
+	stop           chan struct{}
+}
 
-	guildTokens, err := config.DiscordToken.GetValues()
-	if err != nil {
-		log.Panic().Err(err).Msg("Failed to get discord tokens")
+type shard struct {
+	*dg.Session
+	ready     chan struct{}
+	readyOnce sync.Once
+}
+
+// Creates a bot manager for every discord token in the database. Sessions identify with the union of the intents
+// required by all event handlers, plus those passed in by the caller (such as tasks).
+func CreateBotManager(requirements ...IntentRequirement) *BotManager {
+	bm := &BotManager{
+		bots:         make(map[string]*botUser),
+		guildBots:    make(map[string]*shard),
+		requirements: collectIntentRequirements(requirements),
+		stop:         make(chan struct{}),
 	}
 
-	// Convert guild->token map to token->[guilds]; some guilds may share the same token/bot user
-	tokenGuilds := make(map[string][]string)
-	for guild, value := range guildTokens {
-		tokenGuilds[value] = append(tokenGuilds[value], guild)
+	tokenGuilds, err := loadTokenGuilds()
+	if err != nil {
+		log.Panic().Err(err).Msg("Failed to get discord tokens")
 	}
 
 	// Create all bots
 	for token, guilds := range tokenGuilds {
-		bot := createBot(token, guilds, &bm.ready)
-		bm.bots = append(bm.bots, bot)
-		for _, guild := range guilds {
-			bm.GuildBots[guild] = bot
-		}
+		bm.bots[token] = bm.createBot(token, guilds)
 	}
+	bm.updateGuildBots()
 
 	return bm
 }
 
+// Loads the discord tokens from config and converts the guild->token map to token->[guilds];
+// some guilds may share the same token/bot user
+func loadTokenGuilds() (map[string][]string, error) {
+	guildTokens, err := config.DiscordToken.GetValues()
+	if err != nil {
+		return nil, err
+	}
+	tokenGuilds := make(map[string][]string)
+	for guild, value := range guildTokens {
+		tokenGuilds[value] = append(tokenGuilds[value], guild)
+	}
+	for _, guilds := range tokenGuilds {
+		slices.Sort(guilds)
+	}
+	return tokenGuilds, nil
+}
+
 // Starts all bots previously created by CreateBots
-// This function will block and return after all bots have reported ready
+// This function will block and return after all shards of all bots have reported ready
 func (bm *BotManager) Start() {
-	bm.stop = make(chan struct{})
-	for _, bot := range bm.bots {
-		bot := bot // each goroutine needs a separate copy
-		bm.wg.Add(1)
-		bm.ready.Add(1)
-		go func() {
-			defer bm.wg.Done()
-
-			if err := bot.Open(); err != nil {
-				log.Panic().Err(err).Msg("Failed to start gateway client")
-			}
+	// shards are opened without the lock, so early interactions can look up bots while later shards identify
+	bm.mu.RLock()
+	bots := lo.Values(bm.bots)
+	bm.mu.RUnlock()
+	for _, bot := range bots {
+		if err := bot.open(); err != nil {
+			log.Panic().Err(err).Msg("Failed to start gateway client")
+		}
+	}
+	bm.mu.Lock()
+	for _, bot := range bots {
+		bm.watchBot(bot)
+	}
+	bm.started = true
+	bm.mu.Unlock()
 
-			<-bm.stop // wait for signal from main to stop the bot
-			log.Info().Msg("Received stop signal. Stopping bot...")
+	for _, bot := range bots {
+		for _, shard := range bot.shards {
+			<-shard.ready
+		}
+	}
+	bm.ready.Store(true)
 
-			if err := bot.Close(); err != nil {
-				log.Error().Err(err).Msg("Failed to close bot session gracefully")
+	bm.wg.Add(1)
+	go func() {
+		defer bm.wg.Done()
+		ticker := time.NewTicker(reloadInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-bm.stop:
+				return
+			case <-ticker.C:
+				if err := bm.Reload(); err != nil {
+					log.Error().Err(err).Msg("Failed to reload bot sessions")
+				}
 			}
-		}()
+		}
+	}()
+}
+
+// Opens the gateway connection for every shard of a bot. Waits between shards for the identify rate limit, so this
+// can take a while; don't hold the manager lock.
+func (bot *botUser) open() error {
+	for i, shard := range bot.shards {
+		if i > 0 && i%bot.maxConcurrency == 0 {
+			time.Sleep(identifyWindow) // identify rate limit
+		}
+		if err := shard.Open(); err != nil {
+			bot.close(bot.shards[:i])
+			return err
+		}
+	}
+	return nil
+}
+
+func (bot *botUser) close(shards []*shard) {
+	for _, shard := range shards {
+		if err := shard.Close(); err != nil {
+			log.Error().Err(err).Int("shard", shard.ShardID).Msg("Failed to close bot session gracefully")
+		}
 	}
-	bm.ready.Wait()
+}
+
+// Keeps the shards of an opened bot open until the manager or that bot is stopped. Must be called with the manager
+// lock held, so Stop waits for it.
+func (bm *BotManager) watchBot(bot *botUser) {
+	bm.wg.Add(1)
+	go func() {
+		defer bm.wg.Done()
+
+		select { // wait for signal from main to stop all bots, or from reload to stop this one
+		case <-bm.stop:
+		case <-bot.stop:
+		}
+		log.Info().Strs("guilds", bot.Guilds()).Msg("Received stop signal. Stopping bot...")
+		bot.close(bot.shards)
+	}()
 }
 
 func (bm *BotManager) Stop() {
+	bm.mu.Lock()
 	close(bm.stop)
+	bm.mu.Unlock()
 	bm.wg.Wait()
 }
 
-func createBot(token string, guilds []string, ready *sync.WaitGroup) *dg.Session {
-	var logger = log.Logger // will have the bot name attached once bot starts and figures out who it is
+// Reload reconciles the running sessions with the discord tokens currently in the database.
+// Sessions are opened for new tokens and closed for removed ones; guilds moved between tokens are reassigned.
+// The shard count of an existing bot is only recomputed when its token is removed and added again.
+// Guilds whose enabled features changed since their commands were registered are then re-registered.
+func (bm *BotManager) Reload() error {
+	if err := bm.reconcile(); err != nil {
+		return err
+	}
+	bm.syncChangedFeatures()
+	return nil
+}
 
-	bot, err := dg.New("Bot " + token)
+// Discord is only called without the manager lock held, since creating and opening bots takes seconds to minutes and
+// every interaction, task and error report looks up bots through the manager.
+func (bm *BotManager) reconcile() error {
+	bm.reloadMu.Lock()
+	defer bm.reloadMu.Unlock()
+	tokenGuilds, err := loadTokenGuilds()
 	if err != nil {
-		logger.Panic().Err(err).Strs("guilds", guilds).Msg("Failed to start bot")
+		return err
 	}
 
-	bot.Identify.Intents = dg.IntentsAll
+	bm.mu.RLock()
+	existing := maps.Clone(bm.bots)
+	started := bm.started
+	bm.mu.RUnlock()
 
-	// Bot onInteraction
-	bot.AddHandler(func(s *dg.Session, i *dg.InteractionCreate) {
-		defer func() {
-			if rec := recover(); rec != nil {
-				logger.Error().Err(rec.(error)).Bytes("stack", debug.Stack()).Msg("Captured panic during command handling")
+	added := make(map[string]*botUser)
+	for token, guilds := range tokenGuilds {
+		if bot, ok := existing[token]; ok {
+			bot.setGuilds(guilds)
+			continue
+		}
+		log.Info().Strs("guilds", guilds).Msg("Discord token added. Opening bot session.")
+		bot := bm.createBot(token, guilds)
+		if started {
+			if err := bot.open(); err != nil {
+				log.Error().Err(err).Strs("guilds", guilds).Msg("Failed to start gateway client")
+				continue
 			}
-		}()
-
-		switch i.Type {
-		case dg.InteractionApplicationCommand, dg.InteractionApplicationCommandAutocomplete:
-			data := i.ApplicationCommandData()
-			name := data.Name
-			cmd := *botCommands[name]
-			logger.Debug().Str("type", i.Type.String()).Str("guild", i.GuildID).Str("name", name).Any("options", data.Options).Msg("Received application command")
-			if err := cmd.CommandHandler(&commands.CommandData{
-				Session:           s,
-				InteractionCreate: i,
-				Log:               logger.With().Str("interaction", i.Type.String()).Str("command", name).Str("guild", i.GuildID).Str("author", i.Member.User.Username).Logger(),
-			}); err != nil {
-				logger.Error().Any("interaction", i).Err(err).Msg("Error handling interaction")
+		}
+		added[token] = bot
+	}
+
+	bm.mu.Lock()
+	defer bm.mu.Unlock()
+	select {
+	case <-bm.stop:
+		// shutting down; the new bots were never watched, so Stop won't close them
+		for _, bot := range added {
+			if started {
+				bot.close(bot.shards)
 			}
-		default:
-			logger.Warn().Any("event", i).Msg("Received unreconized interaction create event. This event has been ignored.")
 		}
+		return nil
+	default:
+	}
+	for token, bot := range bm.bots {
+		if _, ok := tokenGuilds[token]; !ok {
+			log.Info().Strs("guilds", bot.Guilds()).Msg("Discord token removed. Closing bot session.")
+			close(bot.stop)
+			delete(bm.bots, token)
+		}
+	}
+	for token, bot := range added {
+		if started {
+			bm.watchBot(bot)
+		}
+		bm.bots[token] = bot
+	}
+	bm.updateGuildBots()
+	return nil
+}
+
+// Re-registers commands in guilds where the enabled features no longer match the registered commands.
+func (bm *BotManager) syncChangedFeatures() {
+	bm.mu.RLock()
+	guildBots := maps.Clone(bm.guildBots)
+	bm.mu.RUnlock()
+	for guildID, shard := range guildBots {
+		features.ForgetConfig(guildID)
+		if !shard.DataReady || !featuresChanged(guildID) {
+			continue
+		}
+		log.Info().Str("guild", guildID).Msg("Enabled features changed. Registering application commands.")
+		if err := shard.syncCommands(shard.State.Application.ID, guildID, false); err != nil {
+			log.Error().Err(err).Str("guild", guildID).Msg("Failed to register application commands.")
+		}
+	}
+}
+
+// Rebuilds the guild->shard lookup. Must be called with the manager lock held.
+func (bm *BotManager) updateGuildBots() {
+	bm.guildBots = make(map[string]*shard)
+	for _, bot := range bm.bots {
+		for _, guild := range bot.Guilds() {
+			bm.guildBots[guild] = bot.shardFor(guild)
+		}
+	}
+}
+
+// Returns the session responsible for a guild, which is the shard of the guild's bot that receives its events.
+func (bm *BotManager) GetBot(guildID string) (*dg.Session, bool) {
+	bm.mu.RLock()
+	defer bm.mu.RUnlock()
+	if shard, ok := bm.guildBots[guildID]; ok {
+		return shard.Session, true
+	}
+	return nil, false
+}
+
+// Returns a session of the bot with the given application ID, e.g. to send DMs from the bot a user talked to. Only
+// finds bots that have connected at least once.
+func (bm *BotManager) GetApplicationBot(appID string) (*dg.Session, bool) {
+	bm.mu.RLock()
+	defer bm.mu.RUnlock()
+	for _, bot := range bm.bots {
+		// REST calls work from any shard
+		if shard := bot.shards[0]; shard.State.Application != nil && shard.State.Application.ID == appID {
+			return shard.Session, true
+		}
+	}
+	return nil, false
+}
+
+// Registers the application commands in a guild, skipping the overwrite if nothing changed unless forced.
+func (bm *BotManager) RegisterCommands(guildID string, force bool) error {
+	bm.mu.RLock()
+	shard, ok := bm.guildBots[guildID]
+	bm.mu.RUnlock()
+	if !ok {
+		return fmt.Errorf("no bot found for guild %s", guildID)
+	}
+	return shard.syncCommands(shard.State.Application.ID, guildID, force)
+}
+
+func (bot *botUser) Guilds() []string {
+	bot.mu.RLock()
+	defer bot.mu.RUnlock()
+	return bot.guilds
+}
+
+// Returns the guilds of this bot that are routed to the given shard.
+func (bot *botUser) shardGuilds(shardID int) []string {
+	return lo.Filter(bot.Guilds(), func(guild string, _ int) bool {
+		return bot.shardFor(guild).ShardID == shardID
+	})
+}
+
+// Routes a guild to a shard using discord's sharding formula: (guild_id >> 22) % num_shards
+func (bot *botUser) shardFor(guildID string) *shard {
+	id, err := strconv.ParseUint(guildID, 10, 64)
+	if err != nil {
+		log.Warn().Str("guild", guildID).Msg("Guild ID is not a snowflake. Routing to the first shard.")
+		return bot.shards[0]
+	}
+	return bot.shards[(id>>22)%uint64(len(bot.shards))]
+}
+
+// Replaces the guild list of a running bot, registering commands in any newly added guilds.
+func (bot *botUser) setGuilds(guilds []string) {
+	bot.mu.Lock()
+	added, _ := lo.Difference(guilds, bot.guilds)
+	bot.guilds = guilds
+	bot.mu.Unlock()
+
+	for _, guild := range added {
+		if shard := bot.shardFor(guild); shard.DataReady {
+			log.Info().Str("guild", guild).Int("shard", shard.ShardID).Msg("Guild added to existing bot. Registering application commands.")
+			if err := shard.syncCommands(shard.State.Application.ID, guild, false); err != nil {
+				log.Error().Err(err).Str("guild", guild).Msg("Failed to register application commands.")
+			}
+		}
+	}
+}
+
+// Creates a bot user, asking discord for the recommended number of shards and the privileged intents it has access to.
+func (bm *BotManager) createBot(token string, guilds []string) *botUser {
+	bot := &botUser{guilds: guilds, maxConcurrency: 1, stop: make(chan struct{})}
+
+	probe, err := dg.New("Bot " + token)
+	if err != nil {
+		log.Panic().Err(err).Strs("guilds", guilds).Msg("Failed to start bot")
+	}
+
+	if app, err := probe.Application("@me"); err != nil {
+		log.Warn().Err(err).Strs("guilds", guilds).Msg("Failed to fetch application info. Requesting all required intents without checking.")
+		bot.intents = resolveIntents(&dg.Application{Flags: ^0}, bm.requirements)
+	} else {
+		bot.intents = resolveIntents(app, bm.requirements)
+	}
+	log.Info().Strs("guilds", guilds).Str("intents", formatIntents(bot.intents)).Msg("Resolved gateway intents.")
+
+	shardCount := 1
+	if gateway, err := probe.GatewayBot(); err != nil {
+		log.Warn().Err(err).Strs("guilds", guilds).Msg("Failed to get recommended shard count. Using a single shard.")
+	} else {
+		shardCount = max(gateway.Shards, 1)
+		bot.maxConcurrency = max(gateway.SessionStartLimit.MaxConcurrency, 1)
+		log.Info().Strs("guilds", guilds).Int("shards", shardCount).Int("maxConcurrency", bot.maxConcurrency).Msg("Fetched recommended shard count.")
+	}
+
+	for id := range shardCount {
+		bot.shards = append(bot.shards, bm.createShard(token, bot, id, shardCount))
+	}
+	return bot
+}
+
+func (bm *BotManager) createShard(token string, bot *botUser, shardID int, shardCount int) *shard {
+	var logger = log.Logger.With().Int("shard", shardID).Logger() // will have the bot name attached once bot starts and figures out who it is
+
+	session, err := dg.New("Bot " + token)
+	if err != nil {
+		logger.Panic().Err(err).Strs("guilds", bot.Guilds()).Msg("Failed to start bot")
+	}
+	shard := &shard{Session: session, ready: make(chan struct{})}
+
+	shard.ShardID = shardID
+	shard.ShardCount = shardCount
+	shard.Identify.Intents = bot.intents
+
+	// Bot onInteraction
+	shard.AddHandler(func(s *dg.Session, i *dg.InteractionCreate) {
+		HandleInteraction(s, i, bm, logger)
 	})
 
 	// Bot onReady
-	bot.AddHandler(func(s *dg.Session, r *dg.Ready) {
+	shard.AddHandler(func(s *dg.Session, r *dg.Ready) {
 		if app, err := s.Application("@me"); err == nil {
 			logger = logger.With().Str("application", app.Name).Logger()
 		} else {
@@ -122,33 +423,142 @@ func createBot(token string, guilds []string, ready *sync.WaitGroup) *dg.Session
 		}
 		logger.Info().Str("bot", r.User.Username).Msg("Bot started.")
 
-		// Register commands on discord
-		logger.Info().Msg("Registering application commands.")
-		for _, guildId := range guilds {
-			if _, err := bot.ApplicationCommandBulkOverwrite(r.Application.ID, guildId, commandList); err != nil {
-				logger.Error().Err(err).Msg("Failed to register application commands.")
+		// Register commands on discord, only for the guilds this shard receives events for
+		for _, guildID := range bot.shardGuilds(shardID) {
+			if err := shard.syncCommands(r.Application.ID, guildID, false); err != nil {
+				logger.Error().Err(err).Str("guild", guildID).Msg("Failed to register application commands.")
+			}
+		}
+		// global commands belong to the application, so one shard registers them
+		if shardID == 0 {
+			if err := shard.syncCommands(r.Application.ID, "", false); err != nil {
+				logger.Error().Err(err).Msg("Failed to register global application commands.")
 			}
 		}
 
 		logger.Info().Msg("Bot is now ready to accept commands.")
-		ready.Done()
+		shard.readyOnce.Do(func() { close(shard.ready) })
 	})
 
 	// Register application event handlers
 	for _, event := range events.Events {
-		bot.AddHandler(event)
+		shard.AddHandler(event.Handler)
 	}
-	return bot
+	return shard
 }
 
-var commandList []*dg.ApplicationCommand = lo.Map(
-	commands.Commands,
-	func(item *commands.BotCommand, _ int) *dg.ApplicationCommand {
-		// Automatically insert all the i18n stuff, so I don't have to keep repeating them everywhere
-		item.Build()
-		cmd := &item.ApplicationCommand
-		return cmd
-	},
-)
+var botComponents = lo.KeyBy(commands.Components, func(item *commands.Component) string { return item.Prefix })
+
+var botCommands = lo.KeyBy(commands.Commands, func(item *commands.BotCommand) string {
+	// Automatically insert all the i18n stuff, so I don't have to keep repeating them everywhere
+	return item.Build().Name
+})
+
+// Dispatches an interaction to the command tree, or to a component handler for buttons, select menus and modals.
+// Used by every session, and by the replay harness to feed recorded interactions into the commands.
+func HandleInteraction(s *dg.Session, i *dg.InteractionCreate, manager commands.Manager, logger zerolog.Logger) {
+	var path string
+	var handler func(cd *commands.CommandData) error
+	deferPublic := false
+	switch i.Type {
+	case dg.InteractionApplicationCommand, dg.InteractionApplicationCommandAutocomplete:
+		data := i.ApplicationCommandData()
+		path = commandPath(data)
+		logger.Debug().Str("type", i.Type.String()).Str("guild", i.GuildID).Str("name", data.Name).Any("options", data.Options).Msg("Received application command")
+		cmd, ok := botCommands[data.Name]
+		if !ok {
+			// e.g. a command that was removed, but is still registered with discord
+			logger.Warn().Str("name", data.Name).Msg("Received application command without a handler. This event has been ignored.")
+			return
+		}
+		handler = cmd.CommandHandler
+		if leaf := cmd.Lookup(path); leaf != nil {
+			deferPublic = leaf.DeferPublic
+		}
+	case dg.InteractionMessageComponent, dg.InteractionModalSubmit:
+		customID := lo.TernaryF(i.Type == dg.InteractionModalSubmit,
+			func() string { return i.ModalSubmitData().CustomID },
+			func() string { return i.MessageComponentData().CustomID })
+		prefix, args := commands.ParseCustomID(customID)
+		component, ok := botComponents[prefix]
+		if !ok {
+			logger.Warn().Str("customID", customID).Msg("Received component interaction without a handler. This event has been ignored.")
+			return
+		}
+		path = "component:" + prefix
+		logger.Debug().Str("type", i.Type.String()).Str("guild", i.GuildID).Str("customID", customID).Msg("Received component interaction")
+		handler = func(cd *commands.CommandData) error { return component.Handler(cd, args) }
+	default:
+		logger.Warn().Any("event", i).Msg("Received unreconized interaction create event. This event has been ignored.")
+		return
+	}
+
+	done, ok := background.Track("command:" + path)
+	if !ok {
+		return
+	}
+	defer done()
+	cd := &commands.CommandData{
+		Session:           s,
+		InteractionCreate: i,
+		Manager:           manager,
+	}
+	cd.Log = logger.With().Str("interaction", i.Type.String()).Str("command", path).Str("guild", i.GuildID).Str("author", cd.Invoker().Username).Logger()
+	// autocomplete interactions cannot be deferred
+	if i.Type != dg.InteractionApplicationCommandAutocomplete {
+		stop := cd.AutoDefer(commands.AutoDeferAfter, deferPublic)
+		defer stop()
+	}
+	start := time.Now()
+	outcome := metrics.OutcomePanic
+	defer func() {
+		metrics.InteractionDuration.WithLabelValues(path, i.Type.String()).Observe(time.Since(start).Seconds())
+		metrics.Interactions.WithLabelValues(path, i.Type.String(), outcome, i.GuildID).Inc()
+	}()
+	defer func() {
+		if rec := recover(); rec != nil {
+			reportInteractionError(cd, path, errorreport.PanicError(rec), debug.Stack())
+		}
+	}()
+	err := handler(cd)
+	outcome = metrics.Outcome(err)
+	if err != nil {
+		reportInteractionError(cd, path, err, nil)
+	}
+}
 
-var botCommands = lo.KeyBy(commands.Commands, func(item *commands.BotCommand) string { return item.Name })
+// Full path of the invoked command including subcommand groups and subcommands, e.g. "quotes/find"
+func commandPath(data dg.ApplicationCommandInteractionData) string {
+	path := data.Name
+	options := data.Options
+	for len(options) > 0 && (options[0].Type == dg.ApplicationCommandOptionSubCommandGroup || options[0].Type == dg.ApplicationCommandOptionSubCommand) {
+		path += "/" + options[0].Name
+		options = options[0].Options
+	}
+	return path
+}
+
+// Logs a failed interaction, posts it to the guild's error channel and tells the user it failed. Autocomplete
+// interactions cannot be answered with a message, so those are only logged and reported.
+func reportInteractionError(cd *commands.CommandData, path string, err error, stack []byte) {
+	id := errorreport.NewID()
+	if stack != nil {
+		cd.Log.Error().Str("id", id).Err(err).Bytes("stack", stack).Msg("Captured panic during command handling")
+	} else {
+		cd.Log.Error().Str("id", id).Any("interaction", cd.InteractionCreate).Err(err).Msg("Error handling interaction")
+	}
+	errorreport.Send(cd.Session, errorreport.Report{
+		ID:      id,
+		Source:  errorreport.SourceCommand,
+		Name:    path,
+		GuildID: cd.GuildID,
+		UserID:  cd.Invoker().ID,
+		Err:     err,
+		Stack:   stack,
+	})
+	if cd.Type != dg.InteractionApplicationCommandAutocomplete {
+		if err := cd.RespondError(id); err != nil {
+			cd.Log.Warn().Str("id", id).Err(err).Msg("Failed to tell the user about the error")
+		}
+	}
+}
@@ -0,0 +1,20 @@
+package bot_test
+
+import (
+	"snoozybot/internal/replay"
+	"testing"
+
+	dg "github.com/bwmarrin/discordgo"
+)
+
+func TestHandleInteractionUnknownCommand(t *testing.T) {
+	session := replay.NewSession()
+	member := &dg.Member{User: &dg.User{ID: "400000000000000001", Username: "author"}}
+
+	// must not panic, since nothing would recover it
+	session.Interact(replay.CommandInteraction("200000000000000001", "300000000000000001", member, "removed"))
+
+	if calls := session.API.Calls(); len(calls) != 0 {
+		t.Errorf("made calls %v, want none", calls)
+	}
+}
@@ -0,0 +1,86 @@
+package bot
+
+import (
+	"fmt"
+	"snoozybot/internal/events"
+	"strings"
+
+	dg "github.com/bwmarrin/discordgo"
+	"github.com/rs/zerolog/log"
+	"github.com/samber/lo"
+)
+
+// Gateway intents needed by an event handler, task or other part of the bot
+type IntentRequirement struct {
+	Name    string
+	Intents dg.Intent
+}
+
+// Commands only need the guild cache, which is used to look up the bot's own member and the guild.
+var commandIntents = IntentRequirement{Name: "commands", Intents: dg.IntentGuilds}
+
+// Application flags that grant access to the privileged intents. The "limited" flags are given to unverified bots
+// in less than 100 guilds; either one is enough to request the intent.
+// https://discord.com/developers/docs/resources/application#application-object-application-flags
+var privilegedIntents = map[dg.Intent]int{
+	dg.IntentGuildPresences: 1<<12 | 1<<13,
+	dg.IntentGuildMembers:   1<<14 | 1<<15,
+	dg.IntentMessageContent: 1<<18 | 1<<19,
+}
+
+var intentNames = map[dg.Intent]string{
+	dg.IntentGuilds:          "Guilds",
+	dg.IntentGuildMembers:    "GuildMembers",
+	dg.IntentGuildModeration: "GuildModeration",
+	dg.IntentGuildPresences:  "GuildPresences",
+	dg.IntentGuildMessages:   "GuildMessages",
+	dg.IntentDirectMessages:  "DirectMessages",
+	dg.IntentMessageContent:  "MessageContent",
+}
+
+// Collects the intent requirements of every registered event handler, plus any requirements passed by the caller.
+func collectIntentRequirements(extra []IntentRequirement) []IntentRequirement {
+	requirements := lo.Map(events.Events, func(event *events.EventHandler, _ int) IntentRequirement {
+		return IntentRequirement{Name: event.Name, Intents: event.Intents}
+	})
+	requirements = append(requirements, commandIntents)
+	return append(requirements, extra...)
+}
+
+// Computes the intents to identify with: the union of every requirement, minus the privileged intents that
+// the application has not been approved for. Handlers that lose an intent are logged, since they will not
+// receive (all of) their events.
+func resolveIntents(app *dg.Application, requirements []IntentRequirement) dg.Intent {
+	var requested dg.Intent
+	for _, req := range requirements {
+		requested |= req.Intents
+	}
+	var denied dg.Intent
+	for intent, flags := range privilegedIntents {
+		if requested&intent != 0 && app.Flags&flags == 0 {
+			denied |= intent
+		}
+	}
+	for _, req := range requirements {
+		if missing := req.Intents & denied; missing != 0 {
+			log.Warn().Str("application", app.Name).Str("handler", req.Name).Str("intents", formatIntents(missing)).
+				Msg("Handler requires a privileged intent the application does not have. It will not work until the intent is enabled in the developer portal.")
+		}
+	}
+	return requested &^ denied
+}
+
+func formatIntents(intents dg.Intent) string {
+	var names []string
+	for bit := dg.Intent(1); bit <= intents; bit <<= 1 {
+		if intents&bit == 0 {
+			continue
+		}
+		if name, ok := intentNames[bit]; ok {
+			names = append(names, name)
+		} else {
+			names = append(names, fmt.Sprintf("Intent(%d)", bit))
+		}
+	}
+	return strings.Join(names, "|")
+}
@@ -0,0 +1,166 @@
+package bot
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"snoozybot/internal/commands"
+	"snoozybot/internal/features"
+	"strings"
+	"sync"
+
+	dg "github.com/bwmarrin/discordgo"
+	"github.com/rs/zerolog/log"
+	"github.com/samber/lo"
+)
+
+// Enabled features per guild at the time its commands were last registered
+var registeredFeatures sync.Map // guild -> features.Fingerprint
+
+// Registers the application commands of the features enabled in a guild, or the global commands if guildID is empty.
+// Unless forced, the commands already registered on discord are fetched first, and the overwrite is skipped if they
+// match what the bot would register.
+func (shard *shard) syncCommands(appID string, guildID string, force bool) error {
+	logger := log.With().Str("guild", guildID).Int("shard", shard.ShardID).Logger()
+	fingerprint := features.Fingerprint(guildID)
+	commandList := lo.TernaryF(guildID == "", globalCommands, func() []*dg.ApplicationCommand { return guildCommands(guildID) })
+	if !force {
+		registered, err := shard.ApplicationCommands(appID, guildID)
+		if guildID != "" {
+			// contexts only apply to global commands, but discord may still fill in its defaults
+			for _, cmd := range registered {
+				cmd.Contexts, cmd.IntegrationTypes = nil, nil
+			}
+		}
+		if err != nil {
+			logger.Warn().Err(err).Msg("Failed to fetch registered application commands. Registering anyway.")
+		} else if diff := diffCommands(registered, commandList); len(diff) == 0 {
+			logger.Debug().Msg("Application commands are up to date. Skipping registration.")
+			registeredFeatures.Store(guildID, fingerprint)
+			return nil
+		} else {
+			logger.Info().Strs("diff", diff).Msg("Application commands changed.")
+		}
+	}
+	logger.Info().Bool("force", force).Str("features", fingerprint).Msg("Registering application commands.")
+	if _, err := shard.ApplicationCommandBulkOverwrite(appID, guildID, commandList); err != nil {
+		return err
+	}
+	registeredFeatures.Store(guildID, fingerprint)
+	return nil
+}
+
+// Returns the commands to register in a guild, without the commands of disabled features
+func guildCommands(guildID string) []*dg.ApplicationCommand {
+	return lo.FilterMap(commands.Commands, func(cmd *commands.BotCommand, _ int) (*dg.ApplicationCommand, bool) {
+		return cmd.ForGuild(guildID)
+	})
+}
+
+// Returns the commands to register globally. They only include the parts that work outside guilds, and are limited to
+// those contexts, so guilds don't show them next to their own commands of the same name.
+func globalCommands() []*dg.ApplicationCommand {
+	return lo.FilterMap(commands.Commands, func(cmd *commands.BotCommand, _ int) (*dg.ApplicationCommand, bool) {
+		return cmd.Global()
+	})
+}
+
+func featuresChanged(guildID string) bool {
+	registered, ok := registeredFeatures.Load(guildID)
+	return !ok || registered != features.Fingerprint(guildID)
+}
+
+// Returns a human readable list of differences between the registered and desired commands. An empty list means
+// both are equivalent as far as discord is concerned.
+func diffCommands(registered []*dg.ApplicationCommand, desired []*dg.ApplicationCommand) []string {
+	before := lo.SliceToMap(registered, func(cmd *dg.ApplicationCommand) (string, map[string]string) {
+		return commandKey(cmd), flattenCommand(cmd)
+	})
+	after := lo.SliceToMap(desired, func(cmd *dg.ApplicationCommand) (string, map[string]string) {
+		return commandKey(cmd), flattenCommand(cmd)
+	})
+
+	var diff []string
+	for _, name := range slices.Sorted(maps.Keys(after)) {
+		old, ok := before[name]
+		if !ok {
+			diff = append(diff, "added "+name)
+			continue
+		}
+		updated := after[name]
+		for _, field := range slices.Sorted(maps.Keys(lo.Assign(old, updated))) {
+			if old[field] != updated[field] {
+				diff = append(diff, fmt.Sprintf("changed %s %s: %q -> %q", name, field, old[field], updated[field]))
+			}
+		}
+	}
+	for _, name := range slices.Sorted(maps.Keys(before)) {
+		if _, ok := after[name]; !ok {
+			diff = append(diff, "removed "+name)
+		}
+	}
+	return diff
+}
+
+// Commands are unique by name within each command type
+func commandKey(cmd *dg.ApplicationCommand) string {
+	switch cmd.Type {
+	case dg.UserApplicationCommand:
+		return "user:" + cmd.Name
+	case dg.MessageApplicationCommand:
+		return "message:" + cmd.Name
+	default:
+		return "/" + cmd.Name
+	}
+}
+
+// Flattens a command into field path -> value, normalizing the defaults discord fills in on registered commands.
+func flattenCommand(cmd *dg.ApplicationCommand) map[string]string {
+	fields := map[string]string{
+		"description":                cmd.Description,
+		"nsfw":                       fmt.Sprint(lo.FromPtr(cmd.NSFW)),
+		"default_member_permissions": lo.TernaryF(cmd.DefaultMemberPermissions == nil, lo.Empty[string], func() string { return fmt.Sprint(*cmd.DefaultMemberPermissions) }),
+		"contexts":                   joinSorted(lo.FromPtr(cmd.Contexts)),
+		"integration_types":          joinSorted(lo.FromPtr(cmd.IntegrationTypes)),
+	}
+	flattenLocalizations(fields, "name_localizations", lo.FromPtr(cmd.NameLocalizations))
+	flattenLocalizations(fields, "description_localizations", lo.FromPtr(cmd.DescriptionLocalizations))
+	for i, opt := range cmd.Options {
+		flattenOption(fields, fmt.Sprintf("options[%d]", i), opt)
+	}
+	return fields
+}
+
+func flattenOption(fields map[string]string, prefix string, opt *dg.ApplicationCommandOption) {
+	fields[prefix+".type"] = opt.Type.String()
+	fields[prefix+".name"] = opt.Name
+	fields[prefix+".description"] = opt.Description
+	fields[prefix+".required"] = fmt.Sprint(opt.Required)
+	fields[prefix+".autocomplete"] = fmt.Sprint(opt.Autocomplete)
+	fields[prefix+".min_value"] = lo.TernaryF(opt.MinValue == nil, lo.Empty[string], func() string { return fmt.Sprint(*opt.MinValue) })
+	fields[prefix+".max_value"] = fmt.Sprint(opt.MaxValue)
+	fields[prefix+".min_length"] = lo.TernaryF(opt.MinLength == nil, lo.Empty[string], func() string { return fmt.Sprint(*opt.MinLength) })
+	fields[prefix+".max_length"] = fmt.Sprint(opt.MaxLength)
+	fields[prefix+".channel_types"] = strings.Join(lo.Map(opt.ChannelTypes, func(t dg.ChannelType, _ int) string { return fmt.Sprint(int(t)) }), ",")
+	flattenLocalizations(fields, prefix+".name_localizations", opt.NameLocalizations)
+	flattenLocalizations(fields, prefix+".description_localizations", opt.DescriptionLocalizations)
+	for i, choice := range opt.Choices {
+		choicePrefix := fmt.Sprintf("%s.choices[%d]", prefix, i)
+		fields[choicePrefix+".name"] = choice.Name
+		fields[choicePrefix+".value"] = fmt.Sprint(choice.Value)
+		flattenLocalizations(fields, choicePrefix+".name_localizations", choice.NameLocalizations)
+	}
+	for i, sub := range opt.Options {
+		flattenOption(fields, fmt.Sprintf("%s.options[%d]", prefix, i), sub)
+	}
+}
+
+func flattenLocalizations(fields map[string]string, prefix string, localizations map[dg.Locale]string) {
+	for locale, value := range localizations {
+		fields[prefix+"."+string(locale)] = value
+	}
+}
+
+func joinSorted[T ~uint](values []T) string {
+	return strings.Join(lo.Map(slices.Sorted(slices.Values(values)), func(v T, _ int) string { return fmt.Sprint(uint(v)) }), ",")
+}
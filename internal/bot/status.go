@@ -0,0 +1,46 @@
+package bot
+
+import (
+	"slices"
+	"snoozybot/internal/status"
+
+	dg "github.com/bwmarrin/discordgo"
+	"github.com/samber/lo"
+)
+
+// Reports whether Start has returned, meaning every shard of every bot has been ready at least once.
+func (bm *BotManager) Started() bool {
+	return bm.ready.Load()
+}
+
+// Reports the gateway state of every shard of every bot.
+func (bm *BotManager) SessionStatus() []status.Session {
+	bm.mu.RLock()
+	defer bm.mu.RUnlock()
+	var sessions []status.Session
+	for _, bot := range bm.bots {
+		for _, shard := range bot.shards {
+			session := status.Session{
+				Shard:      shard.ShardID,
+				ShardCount: shard.ShardCount,
+				Ready:      shard.DataReady,
+				LatencyMs:  shard.HeartbeatLatency().Milliseconds(),
+				Guilds:     bot.shardGuilds(shard.ShardID),
+			}
+			shard.State.RLock()
+			if shard.State.User != nil {
+				session.Bot = shard.State.User.Username
+			}
+			session.ConnectedGuilds = lo.Map(shard.State.Guilds, func(guild *dg.Guild, _ int) string { return guild.ID })
+			shard.State.RUnlock()
+			sessions = append(sessions, session)
+		}
+	}
+	slices.SortFunc(sessions, func(a, b status.Session) int {
+		if a.Bot != b.Bot {
+			return lo.Ternary(a.Bot < b.Bot, -1, 1)
+		}
+		return a.Shard - b.Shard
+	})
+	return sessions
+}
@@ -0,0 +1,286 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"snoozybot/internal/database"
+	"snoozybot/internal/features"
+	"snoozybot/internal/i18n"
+	"strings"
+	"text/template"
+
+	dg "github.com/bwmarrin/discordgo"
+	"github.com/samber/lo"
+	"gorm.io/gorm"
+)
+
+// Fragments are template variables, so their keys must be valid identifiers
+var fragmentKeyPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+type actionOptions struct {
+	Action string     `option:"action,required,maxlen=32"`
+	User   *dg.Member `option:"user,required"`
+}
+
+// Runs a targeted action defined by the guild, like the built-in /bap or /hug
+var action = BotCommand{
+	ApplicationCommand: dg.ApplicationCommand{Name: "action"},
+	Feature:            features.Fun,
+	DeferPublic:        true,
+	Middlewares:        []Middleware{GuildOnly, Cooldown(cdm)},
+	Autocomplete:       map[string]Autocomplete{"action": autocompleteActions},
+	Bind: Bind(func(cd *CommandData, opts *actionOptions) error {
+		name := normalizeTagName(opts.Action)
+		var texts []database.ActionText
+		if err := database.Database.Where(&database.ActionText{GuildID: cd.GuildID, Action: name}).Order("id").Find(&texts).Error; err != nil {
+			return err
+		}
+		if len(texts) == 0 {
+			return cd.Respond(Response{Key: "action.missing", Vars: &i18n.Vars{"action": opts.Action}})
+		}
+		variant, target := actionTarget(cd, opts.User)
+		locale, templates := actionTemplates(texts, cd.PublicLocale(), variant)
+		if len(templates) == 0 {
+			// actions don't need texts for every variant; the self and bot texts are only for flavor
+			locale, templates = actionTemplates(texts, cd.PublicLocale(), database.ActionVariantUser)
+		}
+		if len(templates) == 0 {
+			return cd.Respond(Response{Key: "action.noText", Vars: &i18n.Vars{"action": name}})
+		}
+		vars := i18n.SampleFragments(actionFragments(texts, locale))
+		vars["author"] = cd.Interaction.Member.DisplayName()
+		vars["target"] = target
+		cd.Log.Debug().Str("action", name).Str("variant", string(variant)).Str("locale", string(locale)).Any("vars", vars).Msg("Responding to custom action")
+		// texts are written by moderators, so they are rendered with the same limits as tags
+		content, err := renderTag(lo.Sample(templates), &vars)
+		if errors.Is(err, errTagTooLong) {
+			return cd.Respond(Response{Key: "action.tooLong", Vars: &i18n.Vars{"action": name, "max": maxTagLength}})
+		} else if err != nil {
+			return cd.Respond(Response{Key: "action.renderFailed", Vars: &i18n.Vars{"action": name, "error": err.Error()}})
+		}
+		return respondTargeted(cd, Response{InteractionResponseData: dg.InteractionResponseData{Content: content}})
+	}),
+}
+
+// Returns the texts of a variant in the first locale that has any: the guild's locale, the default locale, or else
+// whichever locale was added first
+func actionTemplates(texts []database.ActionText, guildLocale dg.Locale, variant database.ActionVariant) (dg.Locale, []*template.Template) {
+	texts = lo.Filter(texts, func(t database.ActionText, _ int) bool { return t.Variant == variant })
+	if len(texts) == 0 {
+		return "", nil
+	}
+	locale := texts[0].Locale
+	for _, l := range []dg.Locale{guildLocale, dg.EnglishUS} {
+		if slices.ContainsFunc(texts, func(t database.ActionText) bool { return t.Locale == string(l) }) {
+			locale = string(l)
+			break
+		}
+	}
+	var templates []*template.Template
+	for _, t := range texts {
+		if t.Locale != locale {
+			continue
+		}
+		// texts are validated when saved, so this only happens if they were changed in the database or saved before
+		// the rules got stricter
+		if templ, err := parseTag(t.Action, t.Text); err == nil {
+			templates = append(templates, templ)
+		}
+	}
+	return dg.Locale(locale), templates
+}
+
+// Returns the fragments of the locale, with the fragments of the default locale filling in the ones it lacks
+func actionFragments(texts []database.ActionText, locale dg.Locale) map[string][]string {
+	inLocale := map[string][]string{}
+	fallback := map[string][]string{}
+	for _, t := range texts {
+		if t.Variant != database.ActionVariantFragment {
+			continue
+		}
+		if t.Locale == string(locale) {
+			inLocale[t.Fragment] = append(inLocale[t.Fragment], t.Text)
+		} else if t.Locale == string(dg.EnglishUS) {
+			fallback[t.Fragment] = append(fallback[t.Fragment], t.Text)
+		}
+	}
+	return lo.Assign(fallback, inLocale)
+}
+
+var actions = BotCommand{
+	ApplicationCommand: dg.ApplicationCommand{Name: "actions", DefaultMemberPermissions: &CommandPermissionModeratorOnly},
+	Feature:            features.Fun,
+	Middlewares:        []Middleware{GuildOnly, RequirePermissions(dg.PermissionManageMessages), AuditLog},
+	Subcommands: []*BotCommand{
+		&actionsAdd,
+		&actionsFragment,
+		&actionsRemove,
+		&actionsDelete,
+		&actionsList,
+	},
+}
+
+type actionsAddOptions struct {
+	Name    string `option:"name,required,maxlen=32"`
+	Variant string `option:"variant,required,choices=user|self|bot"`
+	Text    string `option:"text,required,maxlen=1000"`
+	Locale  string `option:"locale,choices=en-US|es-419|fr|zh-CN"`
+}
+
+// Adds one text to an action, creating the action if it's new
+var actionsAdd = BotCommand{
+	ApplicationCommand: dg.ApplicationCommand{Name: "add"},
+	Autocomplete:       map[string]Autocomplete{"name": autocompleteActions},
+	Bind: Bind(func(cd *CommandData, opts *actionsAddOptions) error {
+		name := normalizeTagName(opts.Name)
+		// same rules as tag names, since both are typed by users
+		if !tagNamePattern.MatchString(name) {
+			return cd.Respond(Response{Key: "actions.invalidName"})
+		}
+		if _, err := parseTag(name, opts.Text); err != nil {
+			return cd.Respond(Response{Key: "actions.invalidTemplate", Vars: &i18n.Vars{"error": err.Error()}})
+		}
+		text := database.ActionText{
+			GuildID: cd.GuildID,
+			Action:  name,
+			Locale:  lo.CoalesceOrEmpty(opts.Locale, string(cd.PublicLocale())),
+			Variant: database.ActionVariant(opts.Variant),
+			Text:    opts.Text,
+			AddedBy: cd.Invoker().ID,
+		}
+		if err := database.Database.Create(&text).Error; err != nil {
+			return err
+		}
+		cd.Log.Info().Str("action", name).Uint("id", text.ID).Msg("Added action text")
+		return cd.Respond(Response{Key: "actions.add.success", Vars: &i18n.Vars{"action": name, "id": text.ID}})
+	}),
+}
+
+type actionsFragmentOptions struct {
+	Name   string `option:"name,required,maxlen=32"`
+	Key    string `option:"key,required,maxlen=32"`
+	Values string `option:"values,required,maxlen=2000"`
+	Locale string `option:"locale,choices=en-US|es-419|fr|zh-CN"`
+}
+
+// Replaces the values of a fragment, e.g. {{ .object }}, in one locale
+var actionsFragment = BotCommand{
+	ApplicationCommand: dg.ApplicationCommand{Name: "fragment"},
+	Autocomplete:       map[string]Autocomplete{"name": autocompleteActions},
+	Bind: Bind(func(cd *CommandData, opts *actionsFragmentOptions) error {
+		name := normalizeTagName(opts.Name)
+		if !tagNamePattern.MatchString(name) {
+			return cd.Respond(Response{Key: "actions.invalidName"})
+		}
+		if !fragmentKeyPattern.MatchString(opts.Key) {
+			return cd.Respond(Response{Key: "actions.invalidKey", Vars: &i18n.Vars{"key": opts.Key}})
+		}
+		base := database.ActionText{
+			GuildID:  cd.GuildID,
+			Action:   name,
+			Locale:   lo.CoalesceOrEmpty(opts.Locale, string(cd.PublicLocale())),
+			Variant:  database.ActionVariantFragment,
+			Fragment: opts.Key,
+			AddedBy:  cd.Invoker().ID,
+		}
+		values := lo.Compact(lo.Map(strings.Split(opts.Values, "|"), func(v string, _ int) string { return strings.TrimSpace(v) }))
+		if len(values) == 0 {
+			return cd.Respond(Response{Key: "actions.fragment.empty"})
+		}
+		texts := lo.Map(values, func(v string, _ int) *database.ActionText {
+			text := base
+			text.Text = v
+			return &text
+		})
+		err := database.Database.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Where(&database.ActionText{GuildID: base.GuildID, Action: base.Action, Locale: base.Locale, Variant: base.Variant, Fragment: base.Fragment}).
+				Delete(&database.ActionText{}).Error; err != nil {
+				return err
+			}
+			return tx.Create(&texts).Error
+		})
+		if err != nil {
+			return err
+		}
+		cd.Log.Info().Str("action", name).Str("key", opts.Key).Int("count", len(values)).Msg("Set action fragment")
+		return cd.Respond(Response{Key: "actions.fragment.success", Vars: &i18n.Vars{"action": name, "key": opts.Key, "count": len(values)}})
+	}),
+}
+
+type actionsRemoveOptions struct {
+	ID int64 `option:"id,required,min=1"`
+}
+
+// Removes one text, by the ID shown in /actions list
+var actionsRemove = BotCommand{
+	ApplicationCommand: dg.ApplicationCommand{Name: "remove"},
+	Bind: Bind(func(cd *CommandData, opts *actionsRemoveOptions) error {
+		res := database.Database.Where(&database.ActionText{GuildID: cd.GuildID}).Delete(&database.ActionText{ID: uint(opts.ID)})
+		if res.Error != nil {
+			return res.Error
+		} else if res.RowsAffected == 0 {
+			return cd.Respond(Response{Key: "actions.remove.missing"})
+		}
+		cd.Log.Info().Int64("id", opts.ID).Msg("Removed action text")
+		return cd.Respond(Response{Key: "actions.remove.success"})
+	}),
+}
+
+type actionsDeleteOptions struct {
+	Name string `option:"name,required,maxlen=32"`
+}
+
+var actionsDelete = BotCommand{
+	ApplicationCommand: dg.ApplicationCommand{Name: "delete"},
+	Autocomplete:       map[string]Autocomplete{"name": autocompleteActions},
+	Bind: Bind(func(cd *CommandData, opts *actionsDeleteOptions) error {
+		name := normalizeTagName(opts.Name)
+		res := database.Database.Where(&database.ActionText{GuildID: cd.GuildID, Action: name}).Delete(&database.ActionText{})
+		if res.Error != nil {
+			return res.Error
+		} else if res.RowsAffected == 0 {
+			return cd.Respond(Response{Key: "action.missing", Vars: &i18n.Vars{"action": opts.Name}})
+		}
+		cd.Log.Info().Str("action", name).Int64("count", res.RowsAffected).Msg("Deleted action")
+		return cd.Respond(Response{Key: "actions.delete.success", Vars: &i18n.Vars{"action": name}})
+	}),
+}
+
+type actionsListOptions struct {
+	Name string `option:"name,maxlen=32"`
+}
+
+var actionsList = BotCommand{
+	ApplicationCommand: dg.ApplicationCommand{Name: "list"},
+	Autocomplete:       map[string]Autocomplete{"name": autocompleteActions},
+	Bind: Bind(func(cd *CommandData, opts *actionsListOptions) error {
+		return actionTextPages.Respond(cd, normalizeTagName(opts.Name))
+	}),
+}
+
+// The only argument is the action to list the texts of, or "" for all actions
+var actionTextPages = newPaginator(Paginator{
+	Name:     "actions",
+	PageSize: 10,
+	EmptyKey: "actions.list.empty",
+	Count: func(cd *CommandData, args []string) (int64, error) {
+		var count int64
+		return count, database.Database.Model(&database.ActionText{}).Where(&database.ActionText{GuildID: cd.GuildID, Action: args[0]}).Count(&count).Error
+	},
+	Page: func(cd *CommandData, args []string, offset int, limit int) ([]*dg.MessageEmbedField, error) {
+		var texts []*database.ActionText
+		if res := database.Database.Where(&database.ActionText{GuildID: cd.GuildID, Action: args[0]}).
+			Order("action, locale, variant, fragment, id").Offset(offset).Limit(limit).Find(&texts); res.Error != nil {
+			return nil, res.Error
+		}
+		return lo.Map(texts, func(t *database.ActionText, _ int) *dg.MessageEmbedField {
+			variant := lo.Ternary(t.Variant == database.ActionVariantFragment, "{{ ."+t.Fragment+" }}", string(t.Variant))
+			return &dg.MessageEmbedField{
+				Name:  fmt.Sprintf("#%d %s · %s · %s", t.ID, t.Action, variant, t.Locale),
+				Value: lo.Ellipsis(t.Text, 200),
+			}
+		}), nil
+	},
+})
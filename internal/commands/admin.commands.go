@@ -0,0 +1,166 @@
+package commands
+
+import (
+	"fmt"
+	"snoozybot/internal/database"
+	"snoozybot/internal/i18n"
+	"strings"
+
+	dg "github.com/bwmarrin/discordgo"
+	"github.com/samber/lo"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type adminCommandsRuleOptions struct {
+	Command string      `option:"command,required,maxlen=100"`
+	Channel *dg.Channel `option:"channel"`
+	Role    *dg.Role    `option:"role"`
+}
+
+var adminCommandsAllow = BotCommand{
+	ApplicationCommand: dg.ApplicationCommand{Name: "allow"},
+	Bind:               Bind(setCommandRule(true)),
+}
+
+var adminCommandsDeny = BotCommand{
+	ApplicationCommand: dg.ApplicationCommand{Name: "deny"},
+	Bind:               Bind(setCommandRule(false)),
+}
+
+func setCommandRule(allow bool) func(cd *CommandData, opts *adminCommandsRuleOptions) error {
+	return func(cd *CommandData, opts *adminCommandsRuleOptions) error {
+		path, ok := commandRulePath(opts.Command)
+		if !ok {
+			return cd.Respond(Response{Key: "admin.commands.unknownCommand", Vars: &i18n.Vars{"command": opts.Command}})
+		}
+		rules := commandRuleTargets(cd.GuildID, path, opts)
+		if len(rules) == 0 {
+			return cd.Respond(Response{Key: "admin.commands.noTarget"})
+		}
+		for _, rule := range rules {
+			rule.Allow = allow
+		}
+		if err := database.Database.Clauses(clause.OnConflict{UpdateAll: true}).Create(&rules).Error; err != nil {
+			return err
+		}
+		database.CommandRuleCache.Remove(cd.GuildID)
+		cd.Log.Info().Str("path", path).Bool("allow", allow).Any("rules", rules).Msg("Set command rules")
+		return cd.Respond(Response{
+			Key:  lo.Ternary(allow, "admin.commands.allow.success", "admin.commands.deny.success"),
+			Vars: &i18n.Vars{"command": path, "targets": strings.Join(lo.Map(rules, ruleMention), ", ")},
+		})
+	}
+}
+
+var adminCommandsClear = BotCommand{
+	ApplicationCommand: dg.ApplicationCommand{Name: "clear"},
+	Bind: Bind(func(cd *CommandData, opts *adminCommandsRuleOptions) error {
+		path, ok := commandRulePath(opts.Command)
+		if !ok {
+			return cd.Respond(Response{Key: "admin.commands.unknownCommand", Vars: &i18n.Vars{"command": opts.Command}})
+		}
+		// without a channel or role, every rule of the command is removed
+		var count int64
+		if rules := commandRuleTargets(cd.GuildID, path, opts); len(rules) > 0 {
+			for _, rule := range rules {
+				res := database.Database.Delete(rule)
+				if res.Error != nil {
+					return res.Error
+				}
+				count += res.RowsAffected
+			}
+		} else {
+			res := commandRuleQuery(cd.GuildID, path).Delete(&database.CommandRule{})
+			if res.Error != nil {
+				return res.Error
+			}
+			count = res.RowsAffected
+		}
+		database.CommandRuleCache.Remove(cd.GuildID)
+		cd.Log.Info().Str("path", path).Int64("count", count).Msg("Cleared command rules")
+		return cd.Respond(Response{Key: "admin.commands.clear.success", Vars: &i18n.Vars{"command": path, "count": count}})
+	}),
+}
+
+type adminCommandsListOptions struct {
+	Command string `option:"command,maxlen=100"`
+}
+
+var adminCommandsList = BotCommand{
+	ApplicationCommand: dg.ApplicationCommand{Name: "list"},
+	Bind: Bind(func(cd *CommandData, opts *adminCommandsListOptions) error {
+		path := ""
+		if opts.Command != "" {
+			var ok bool
+			if path, ok = commandRulePath(opts.Command); !ok {
+				return cd.Respond(Response{Key: "admin.commands.unknownCommand", Vars: &i18n.Vars{"command": opts.Command}})
+			}
+		}
+		return commandRulePages.Respond(cd, path)
+	}),
+}
+
+// The only argument is the command path to list the rules of, or "" for all commands
+var commandRulePages = newPaginator(Paginator{
+	Name:     "rules",
+	PageSize: 10,
+	EmptyKey: "admin.commands.list.empty",
+	Count: func(cd *CommandData, args []string) (int64, error) {
+		var count int64
+		return count, commandRuleQuery(cd.GuildID, args[0]).Model(&database.CommandRule{}).Count(&count).Error
+	},
+	Page: func(cd *CommandData, args []string, offset int, limit int) ([]*dg.MessageEmbedField, error) {
+		var rules []*database.CommandRule
+		if res := commandRuleQuery(cd.GuildID, args[0]).Order("command_path, target_type, target_id").Offset(offset).Limit(limit).Find(&rules); res.Error != nil {
+			return nil, res.Error
+		}
+		return lo.Map(rules, func(rule *database.CommandRule, _ int) *dg.MessageEmbedField {
+			key := fmt.Sprintf("admin.commands.list.%s.%s", lo.Ternary(rule.Allow, "allow", "deny"), rule.TargetType)
+			return &dg.MessageEmbedField{
+				Name:  "/" + rule.CommandPath,
+				Value: i18n.Get(cd.Locale, key, &i18n.Vars{"target": ruleMention(rule, 0)}),
+			}
+		}), nil
+	},
+})
+
+func commandRuleQuery(guildID string, path string) *gorm.DB {
+	return database.Database.Where(&database.CommandRule{GuildID: guildID, CommandPath: path})
+}
+
+// Normalizes a command path typed by an admin, e.g. "/quotes find" -> "quotes/find". Returns false if there is no such
+// command.
+func commandRulePath(command string) (string, bool) {
+	path := strings.Join(strings.Fields(strings.ReplaceAll(strings.TrimPrefix(strings.TrimSpace(command), "/"), "/", " ")), "/")
+	if findCommand(path) == nil {
+		// context menu commands have spaces in their name
+		path = strings.TrimPrefix(strings.TrimSpace(command), "/")
+		if findCommand(path) == nil {
+			return "", false
+		}
+	}
+	return path, true
+}
+
+func commandRuleTargets(guildID string, path string, opts *adminCommandsRuleOptions) []*database.CommandRule {
+	var rules []*database.CommandRule
+	if opts.Channel != nil {
+		rules = append(rules, &database.CommandRule{GuildID: guildID, CommandPath: path, TargetType: database.CommandRuleChannel, TargetID: opts.Channel.ID})
+	}
+	if opts.Role != nil {
+		rules = append(rules, &database.CommandRule{GuildID: guildID, CommandPath: path, TargetType: database.CommandRuleRole, TargetID: opts.Role.ID})
+	}
+	return rules
+}
+
+func ruleMention(rule *database.CommandRule, _ int) string {
+	if rule.TargetType == database.CommandRuleChannel {
+		return "<#" + rule.TargetID + ">"
+	}
+	// the @everyone role has the ID of the guild and cannot be mentioned like other roles
+	if rule.TargetID == rule.GuildID {
+		return "@everyone"
+	}
+	return "<@&" + rule.TargetID + ">"
+}
@@ -0,0 +1,318 @@
+package commands
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"snoozybot/internal/config"
+	"snoozybot/internal/database"
+	"snoozybot/internal/features"
+	"snoozybot/internal/i18n"
+	"strconv"
+	"strings"
+
+	dg "github.com/bwmarrin/discordgo"
+	"github.com/samber/lo"
+)
+
+type adminConfigKeyOptions struct {
+	Key string `option:"key,required,maxlen=100"`
+}
+
+var adminConfigGet = BotCommand{
+	ApplicationCommand: dg.ApplicationCommand{Name: "get"},
+	Autocomplete:       map[string]Autocomplete{"key": autocompleteConfigKeys},
+	Bind: Bind(func(cd *CommandData, opts *adminConfigKeyOptions) error {
+		key, ok := config.FindKey(opts.Key)
+		if !ok {
+			return cd.Respond(Response{Key: "admin.config.unknownKey", Vars: &i18n.Vars{"key": opts.Key}})
+		}
+		raw := key.Raw(cd.GuildID)
+		if raw == "" && key.Default() != "" {
+			return cd.Respond(Response{Key: "admin.config.get.default", Vars: &i18n.Vars{"key": key.String(), "value": key.Default()}})
+		} else if raw == "" {
+			return cd.Respond(Response{Key: "admin.config.get.missing", Vars: &i18n.Vars{"key": key.String()}})
+		}
+		return cd.Respond(Response{Key: "admin.config.get.success", Vars: &i18n.Vars{"key": key.String(), "value": raw}})
+	}),
+}
+
+type adminConfigSetOptions struct {
+	Key   string `option:"key,required,maxlen=100"`
+	Value string `option:"value,required,maxlen=6000"`
+}
+
+var adminConfigSet = BotCommand{
+	ApplicationCommand: dg.ApplicationCommand{Name: "set"},
+	Autocomplete:       map[string]Autocomplete{"key": autocompleteConfigKeys},
+	Bind: Bind(func(cd *CommandData, opts *adminConfigSetOptions) error {
+		key, ok := config.FindKey(opts.Key)
+		if !ok {
+			return cd.Respond(Response{Key: "admin.config.unknownKey", Vars: &i18n.Vars{"key": opts.Key}})
+		}
+		value, err := key.Parse(opts.Value)
+		if err != nil {
+			return cd.Respond(Response{Key: "admin.config.set.invalid", Vars: &i18n.Vars{"key": key.String(), "error": err.Error()}})
+		}
+		if r := validateConfigValue(cd, key, value); r != nil {
+			return cd.Respond(*r)
+		}
+		if err := key.Set(cd.GuildID, value, cd.Invoker().ID); err != nil {
+			return err
+		}
+		cd.Log.Info().Str("key", key.String()).Any("value", value).Msg("Set guild config")
+		syncConfigChange(cd)
+		return cd.Respond(Response{Key: "admin.config.set.success", Vars: &i18n.Vars{"key": key.String(), "value": key.Raw(cd.GuildID)}})
+	}),
+}
+
+var adminConfigUnset = BotCommand{
+	ApplicationCommand: dg.ApplicationCommand{Name: "unset"},
+	Autocomplete:       map[string]Autocomplete{"key": autocompleteConfigKeys},
+	Bind: Bind(func(cd *CommandData, opts *adminConfigKeyOptions) error {
+		key, ok := config.FindKey(opts.Key)
+		if !ok {
+			return cd.Respond(Response{Key: "admin.config.unknownKey", Vars: &i18n.Vars{"key": opts.Key}})
+		}
+		if err := key.Unset(cd.GuildID, cd.Invoker().ID); err != nil {
+			return err
+		}
+		cd.Log.Info().Str("key", key.String()).Msg("Unset guild config")
+		syncConfigChange(cd)
+		return cd.Respond(Response{Key: "admin.config.unset.success", Vars: &i18n.Vars{"key": key.String()}})
+	}),
+}
+
+var adminConfigList = BotCommand{
+	ApplicationCommand: dg.ApplicationCommand{Name: "list"},
+	CommandHandler: func(cd *CommandData) error {
+		return configPages.Respond(cd)
+	},
+}
+
+var adminConfigHistory = BotCommand{
+	ApplicationCommand: dg.ApplicationCommand{Name: "history"},
+	Autocomplete:       map[string]Autocomplete{"key": autocompleteConfigKeys},
+	Bind: Bind(func(cd *CommandData, opts *adminConfigKeyOptions) error {
+		key, ok := config.FindKey(opts.Key)
+		if !ok {
+			return cd.Respond(Response{Key: "admin.config.unknownKey", Vars: &i18n.Vars{"key": opts.Key}})
+		}
+		return configHistoryPages.Respond(cd, key.String())
+	}),
+}
+
+type adminConfigRollbackOptions struct {
+	Key     string `option:"key,required,maxlen=100"`
+	Version int64  `option:"version,required,min=1"`
+}
+
+// Restores the value a key had after one of the versions listed by /admin config history
+var adminConfigRollback = BotCommand{
+	ApplicationCommand: dg.ApplicationCommand{Name: "rollback"},
+	Autocomplete:       map[string]Autocomplete{"key": autocompleteConfigKeys},
+	Bind: Bind(func(cd *CommandData, opts *adminConfigRollbackOptions) error {
+		key, ok := config.FindKey(opts.Key)
+		if !ok {
+			return cd.Respond(Response{Key: "admin.config.unknownKey", Vars: &i18n.Vars{"key": opts.Key}})
+		}
+		err := key.Rollback(cd.GuildID, uint(opts.Version), cd.Invoker().ID)
+		if errors.Is(err, config.ErrUnknownVersion) {
+			return cd.Respond(Response{Key: "admin.config.rollback.missing", Vars: &i18n.Vars{"key": key.String(), "version": opts.Version}})
+		} else if err != nil {
+			return err
+		}
+		cd.Log.Info().Str("key", key.String()).Int64("version", opts.Version).Msg("Rolled back guild config")
+		syncConfigChange(cd)
+		return cd.Respond(Response{Key: "admin.config.rollback.success", Vars: &i18n.Vars{"key": key.String(), "version": opts.Version}})
+	}),
+}
+
+var adminConfigExport = BotCommand{
+	ApplicationCommand: dg.ApplicationCommand{Name: "export"},
+	CommandHandler: func(cd *CommandData) error {
+		document, err := config.Export(cd.GuildID)
+		if err != nil {
+			return err
+		}
+		return cd.Respond(Response{Key: "admin.config.export.success"}.WithFile("config-"+cd.GuildID+".yaml", "application/yaml", bytes.NewReader(document)))
+	},
+}
+
+// Config documents are a few kilobytes at most; anything much larger is not one
+const maxConfigDocument = 256 * 1024
+
+type adminConfigImportOptions struct {
+	File  *dg.MessageAttachment `option:"file,required"`
+	Apply bool                  `option:"apply"`
+}
+
+// Shows the changes a document from /admin config export would make, and makes them if asked to
+var adminConfigImport = BotCommand{
+	ApplicationCommand: dg.ApplicationCommand{Name: "import"},
+	Bind: Bind(func(cd *CommandData, opts *adminConfigImportOptions) error {
+		if opts.File.Size > maxConfigDocument {
+			return cd.Respond(Response{Key: "admin.config.import.tooLarge"})
+		}
+		resp, err := http.Get(opts.File.URL)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		document, err := io.ReadAll(io.LimitReader(resp.Body, maxConfigDocument))
+		if err != nil {
+			return err
+		}
+		changes, err := config.PlanImport(cd.GuildID, document)
+		if err != nil {
+			return cd.Respond(Response{Key: "admin.config.import.invalid", Vars: &i18n.Vars{"error": lo.Ellipsis(err.Error(), 1500)}})
+		}
+		if len(changes) == 0 {
+			return cd.Respond(Response{Key: "admin.config.import.unchanged"})
+		}
+		for _, change := range changes {
+			if change.Value == nil {
+				continue
+			}
+			if r := validateConfigValue(cd, change.Key, change.Value); r != nil {
+				return cd.Respond(*r)
+			}
+		}
+		diff := "```diff\n" + lo.Ellipsis(config.FormatDiff(changes), 1800) + "```"
+		if !opts.Apply {
+			return cd.Respond(Response{Key: "admin.config.import.preview", Vars: &i18n.Vars{"diff": diff, "count": len(changes)}})
+		}
+		if err := config.ApplyImport(cd.GuildID, changes, cd.Invoker().ID); err != nil {
+			return err
+		}
+		cd.Log.Info().Int("count", len(changes)).Msg("Imported guild config")
+		syncConfigChange(cd)
+		return cd.Respond(Response{Key: "admin.config.import.success", Vars: &i18n.Vars{"diff": diff, "count": len(changes)}})
+	}),
+}
+
+// The only argument is the key to list the changes of, newest first
+var configHistoryPages = newPaginator(Paginator{
+	Name:     "confighistory",
+	PageSize: 10,
+	EmptyKey: "admin.config.history.empty",
+	Count: func(cd *CommandData, args []string) (int64, error) {
+		var count int64
+		return count, database.Database.Model(&database.ConfigRevision{}).Where(&database.ConfigRevision{GuildID: cd.GuildID, ConfigKey: args[0]}).Count(&count).Error
+	},
+	Page: func(cd *CommandData, args []string, offset int, limit int) ([]*dg.MessageEmbedField, error) {
+		var revisions []*database.ConfigRevision
+		if err := database.Database.Where(&database.ConfigRevision{GuildID: cd.GuildID, ConfigKey: args[0]}).
+			Order("version DESC").Offset(offset).Limit(limit).Find(&revisions).Error; err != nil {
+			return nil, err
+		}
+		return lo.Map(revisions, func(r *database.ConfigRevision, _ int) *dg.MessageEmbedField {
+			return &dg.MessageEmbedField{
+				Name: fmt.Sprintf("v%d · %s", r.Version, r.CreatedAt.UTC().Format("2006-01-02 15:04 MST")),
+				Value: i18n.Get(cd.Locale, "admin.config.history.change", &i18n.Vars{
+					"actor": configHistoryActor(r.Actor),
+					"old":   configHistoryValue(cd, r.OldValue),
+					"new":   configHistoryValue(cd, r.NewValue),
+				}),
+			}
+		}), nil
+	},
+})
+
+// Changes made in discord are made by users, and the others by tools like the command line
+func configHistoryActor(actor string) string {
+	if _, err := strconv.ParseUint(actor, 10, 64); err == nil {
+		return "<@" + actor + ">"
+	}
+	return "`" + actor + "`"
+}
+
+func configHistoryValue(cd *CommandData, raw []byte) string {
+	if config.IsNull(raw) {
+		return i18n.Get(cd.Locale, "admin.config.list.unset")
+	}
+	return "`" + lo.Ellipsis(string(raw), 400) + "`"
+}
+
+// Every known key, with the guild's value or its default
+var configPages = newPaginator(Paginator{
+	Name:     "config",
+	PageSize: 10,
+	Count: func(cd *CommandData, args []string) (int64, error) {
+		return int64(len(config.GuildKeys)), nil
+	},
+	Page: func(cd *CommandData, args []string, offset int, limit int) ([]*dg.MessageEmbedField, error) {
+		keys := config.GuildKeys[min(offset, len(config.GuildKeys)):min(offset+limit, len(config.GuildKeys))]
+		return lo.Map(keys, func(key config.Key, _ int) *dg.MessageEmbedField {
+			value := key.Raw(cd.GuildID)
+			if value != "" {
+				value = "`" + lo.Ellipsis(value, 200) + "`"
+			} else if key.Default() != "" {
+				value = i18n.Get(cd.Locale, "admin.config.list.default", &i18n.Vars{"value": key.Default()})
+			} else {
+				value = i18n.Get(cd.Locale, "admin.config.list.unset")
+			}
+			return &dg.MessageEmbedField{Name: key.String(), Value: key.Description() + "\n" + value}
+		}), nil
+	},
+})
+
+// Checks values that the type of the key alone does not cover: IDs must belong to channels or roles of the guild, and
+// enabled features must exist. Returns the response to send if the value is invalid.
+func validateConfigValue(cd *CommandData, key config.Key, value any) *Response {
+	var ids []json.Number
+	switch v := value.(type) {
+	case json.Number:
+		ids = []json.Number{v}
+	case []json.Number:
+		ids = v
+	}
+	for _, id := range ids {
+		switch key.IDKind() {
+		case config.IDRole:
+			if !guildHasRole(cd, string(id)) {
+				return &Response{Key: "admin.config.set.unknownRole", Vars: &i18n.Vars{"key": key.String(), "id": string(id)}}
+			}
+		case config.IDChannel:
+			if !guildHasChannel(cd, string(id)) {
+				return &Response{Key: "admin.config.set.unknownChannel", Vars: &i18n.Vars{"key": key.String(), "id": string(id)}}
+			}
+		}
+	}
+	if key == config.FeaturesEnabled {
+		names := lo.Map(features.All, func(f *features.Feature, _ int) string { return f.Name })
+		for _, name := range value.([]string) {
+			if !slices.Contains(names, name) {
+				return &Response{Key: "admin.config.set.unknownFeature", Vars: &i18n.Vars{"key": key.String(), "feature": name, "features": strings.Join(names, ", ")}}
+			}
+		}
+	}
+	return nil
+}
+
+func guildHasChannel(cd *CommandData, id string) bool {
+	channel, err := cd.State.Channel(id)
+	if err != nil {
+		channel, err = cd.Channel(id)
+	}
+	return err == nil && channel.GuildID == cd.GuildID
+}
+
+func guildHasRole(cd *CommandData, id string) bool {
+	if _, err := cd.State.Role(cd.GuildID, id); err == nil {
+		return true
+	}
+	roles, err := cd.GuildRoles(cd.GuildID)
+	return err == nil && slices.ContainsFunc(roles, func(role *dg.Role) bool { return role.ID == id })
+}
+
+// Config changes can enable or disable features, so the guild's commands are registered again if they changed
+func syncConfigChange(cd *CommandData) {
+	if err := cd.Manager.RegisterCommands(cd.GuildID, false); err != nil {
+		cd.Log.Warn().Err(err).Msg("Failed to register application commands after a config change")
+	}
+}
@@ -2,20 +2,31 @@ package commands
 
 import (
 	"snoozybot/internal/config"
+	"snoozybot/internal/database"
 
 	dg "github.com/bwmarrin/discordgo"
 )
 
 var admin = BotCommand{
 	ApplicationCommand: dg.ApplicationCommand{Name: "admin", DefaultMemberPermissions: &CommandPermissionAdminOnly},
+	Middlewares:        []Middleware{GuildOnly, AuditLog},
 	Subcommands: []*BotCommand{
 		&adminConfig,
+		&adminCommands,
 	},
 }
 
 var adminConfig = BotCommand{
 	ApplicationCommand: dg.ApplicationCommand{Name: "config"},
 	Subcommands: []*BotCommand{
+		&adminConfigGet,
+		&adminConfigSet,
+		&adminConfigUnset,
+		&adminConfigList,
+		&adminConfigHistory,
+		&adminConfigRollback,
+		&adminConfigExport,
+		&adminConfigImport,
 		&adminConfigReload,
 	},
 }
@@ -23,8 +34,34 @@ var adminConfig = BotCommand{
 var adminConfigReload = BotCommand{
 	ApplicationCommand: dg.ApplicationCommand{Name: "reload"},
 	CommandHandler: func(cd *CommandData) error {
-		cd.Log.Info().Str("requestedInGuild", cd.GuildID).Str("requestedBy", cd.Member.User.ID).Msg("Reloading all configs")
+		cd.Log.Info().Str("requestedInGuild", cd.GuildID).Str("requestedBy", cd.Invoker().ID).Msg("Reloading all configs")
 		config.ClearCache()
+		database.CommandRuleCache.Purge()
+		if err := cd.Manager.Reload(); err != nil {
+			return err
+		}
 		return cd.Respond(Response{Key: "admin.config.reload.success"})
 	},
 }
+
+var adminCommands = BotCommand{
+	ApplicationCommand: dg.ApplicationCommand{Name: "commands"},
+	Subcommands: []*BotCommand{
+		&adminCommandsRegister,
+		&adminCommandsAllow,
+		&adminCommandsDeny,
+		&adminCommandsClear,
+		&adminCommandsList,
+	},
+}
+
+var adminCommandsRegister = BotCommand{
+	ApplicationCommand: dg.ApplicationCommand{Name: "register"},
+	CommandHandler: func(cd *CommandData) error {
+		cd.Log.Info().Str("requestedInGuild", cd.GuildID).Str("requestedBy", cd.Invoker().ID).Msg("Forcing application command registration")
+		if err := cd.Manager.RegisterCommands(cd.GuildID, true); err != nil {
+			return err
+		}
+		return cd.Respond(Response{Key: "admin.commands.register.success"})
+	},
+}
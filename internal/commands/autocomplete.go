@@ -0,0 +1,142 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"snoozybot/internal/config"
+	"snoozybot/internal/database"
+	"snoozybot/internal/timezones"
+	"strings"
+
+	dg "github.com/bwmarrin/discordgo"
+	"github.com/rs/zerolog/log"
+	"github.com/samber/lo"
+)
+
+// Suggests values for an option from what the user typed so far. Declared per option in BotCommand.Autocomplete, and
+// called automatically for autocomplete interactions, after the middlewares. Discord shows at most 25 choices.
+type Autocomplete func(cd *CommandData, typed string) ([]*dg.ApplicationCommandOptionChoice, error)
+
+// Discord rejects choice names longer than this
+const maxChoiceName = 100
+
+// Answers autocomplete interactions with the provider of the focused option, and passes everything else on to the
+// handler. Autocomplete interactions for options without a provider also reach the handler.
+func (bc *BotCommand) dispatchAutocomplete(handler CommandHandler) CommandHandler {
+	for name := range bc.Autocomplete {
+		option, ok := lo.Find(bc.Options, func(opt *dg.ApplicationCommandOption) bool { return opt.Name == name })
+		if !ok {
+			log.Panic().Str("command", bc.Name).Str("option", name).Msg("Autocomplete provider declared for an option that does not exist")
+		}
+		option.Autocomplete = true
+	}
+	return func(cd *CommandData) error {
+		if cd.Type != dg.InteractionApplicationCommandAutocomplete {
+			return handler(cd)
+		}
+		focused, ok := lo.Find(cd.ApplicationCommandData().Options, func(opt *dg.ApplicationCommandInteractionDataOption) bool { return opt.Focused })
+		if !ok {
+			return handler(cd)
+		}
+		provider, ok := bc.Autocomplete[focused.Name]
+		if !ok {
+			return handler(cd)
+		}
+		choices, err := provider(cd, strings.TrimSpace(fmt.Sprint(focused.Value)))
+		if err != nil {
+			return err
+		}
+		return cd.RespondChoices(choices)
+	}
+}
+
+// The user's upcoming reminders in the guild, matched by ID or message
+func autocompleteReminders(cd *CommandData, typed string) ([]*dg.ApplicationCommandOptionChoice, error) {
+	var tasks []*database.ScheduledTask
+	query := reminderQuery(cd).Where("user_id = ?", cd.Invoker().ID)
+	if typed != "" {
+		query = query.Where("CAST(id AS TEXT) LIKE ? OR payload->>'reason' ILIKE ?", escapeLike(typed)+"%", "%"+escapeLike(typed)+"%")
+	}
+	if err := query.Order("process_after, id").Limit(25).Find(&tasks).Error; err != nil {
+		return nil, err
+	}
+	return lo.Map(tasks, func(t *database.ScheduledTask, _ int) *dg.ApplicationCommandOptionChoice {
+		var payload database.ScheduledTaskReminderPayload
+		if err := json.Unmarshal(t.Payload, &payload); err != nil {
+			cd.Log.Error().Any("payload", t.Payload).Msg("Failed to unmarshal JSON for scheduled task when suggesting reminders.")
+		}
+		name := fmt.Sprintf("#%d %s · %s", t.ID, t.ProcessAfter.UTC().Format("2006-01-02 15:04 MST"), payload.Reason)
+		return &dg.ApplicationCommandOptionChoice{Name: lo.Ellipsis(name, maxChoiceName), Value: t.ID}
+	}), nil
+}
+
+// The guild's quotes, matched by ID or text
+func autocompleteQuotes(cd *CommandData, typed string) ([]*dg.ApplicationCommandOptionChoice, error) {
+	var quotes []*database.Quote
+	query := database.Database.Where(&database.Quote{GuildID: cd.GuildID})
+	if typed != "" {
+		query = query.Where("CAST(id AS TEXT) LIKE ? OR content ILIKE ?", escapeLike(typed)+"%", "%"+escapeLike(typed)+"%")
+	}
+	if err := query.Order("id").Limit(25).Find(&quotes).Error; err != nil {
+		return nil, err
+	}
+	return lo.Map(quotes, func(q *database.Quote, _ int) *dg.ApplicationCommandOptionChoice {
+		return &dg.ApplicationCommandOptionChoice{Name: lo.Ellipsis(fmt.Sprintf("#%d %s", q.ID, q.Content), maxChoiceName), Value: q.ID}
+	}), nil
+}
+
+// Time zone names containing the typed text
+func autocompleteTimezones(cd *CommandData, typed string) ([]*dg.ApplicationCommandOptionChoice, error) {
+	typed = strings.ToLower(typed)
+	var found []*dg.ApplicationCommandOptionChoice
+	for _, timezone := range timezones.TimeZones {
+		if strings.Contains(strings.ToLower(timezone), typed) {
+			found = append(found, &dg.ApplicationCommandOptionChoice{Name: timezone, Value: timezone})
+		}
+		if len(found) >= 25 {
+			break
+		}
+	}
+	return found, nil
+}
+
+// The guild's tags starting with the typed text
+func autocompleteTags(cd *CommandData, typed string) ([]*dg.ApplicationCommandOptionChoice, error) {
+	var names []string
+	if err := database.Database.Model(&database.Tag{}).Where(&database.Tag{GuildID: cd.GuildID}).
+		Where("name LIKE ?", escapeLike(normalizeTagName(typed))+"%").Order("name").Limit(25).Pluck("name", &names).Error; err != nil {
+		return nil, err
+	}
+	return namedChoices(names), nil
+}
+
+// The guild's custom actions starting with the typed text
+func autocompleteActions(cd *CommandData, typed string) ([]*dg.ApplicationCommandOptionChoice, error) {
+	var names []string
+	if err := database.Database.Model(&database.ActionText{}).Where(&database.ActionText{GuildID: cd.GuildID}).
+		Where("action LIKE ?", escapeLike(normalizeTagName(typed))+"%").Distinct("action").Order("action").Limit(25).Pluck("action", &names).Error; err != nil {
+		return nil, err
+	}
+	return namedChoices(names), nil
+}
+
+// Guild config keys containing the typed text, with their descriptions
+func autocompleteConfigKeys(cd *CommandData, typed string) ([]*dg.ApplicationCommandOptionChoice, error) {
+	keys := lo.Filter(config.GuildKeys, func(key config.Key, _ int) bool {
+		return strings.Contains(key.String(), strings.ToLower(typed))
+	})
+	return lo.Map(lo.Subset(keys, 0, 25), func(key config.Key, _ int) *dg.ApplicationCommandOptionChoice {
+		return &dg.ApplicationCommandOptionChoice{Name: lo.Ellipsis(key.String()+" · "+key.Description(), maxChoiceName), Value: key.String()}
+	}), nil
+}
+
+func namedChoices(names []string) []*dg.ApplicationCommandOptionChoice {
+	return lo.Map(names, func(name string, _ int) *dg.ApplicationCommandOptionChoice {
+		return &dg.ApplicationCommandOptionChoice{Name: name, Value: name}
+	})
+}
+
+// Escapes the wildcards of a LIKE pattern, so typed text only matches itself
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
@@ -2,7 +2,10 @@ package commands
 
 import (
 	"fmt"
+	"slices"
+	"snoozybot/internal/features"
 	"snoozybot/internal/i18n"
+	"strings"
 
 	dg "github.com/bwmarrin/discordgo"
 	"github.com/rs/zerolog"
@@ -13,10 +16,24 @@ import (
 var CommandPermissionAdminOnly = int64(0)
 var CommandPermissionModeratorOnly = int64(dg.PermissionManageMessages)
 
+// Contexts of commands that also work outside guilds: in DMs with the bot, and in other DMs and group DMs when the
+// app is installed by the user
+var PersonalContexts = []dg.InteractionContextType{dg.InteractionContextBotDM, dg.InteractionContextPrivateChannel}
+
+// Manager exposes the bot lifecycle operations that commands are allowed to trigger.
+type Manager interface {
+	// Reconcile running bot sessions with the discord tokens in the database
+	Reload() error
+	// Register application commands in a guild. Unless forced, registration is skipped if nothing changed.
+	RegisterCommands(guildID string, force bool) error
+}
+
 type CommandData struct {
 	*dg.Session
 	*dg.InteractionCreate
-	Log zerolog.Logger
+	Manager  Manager
+	Log      zerolog.Logger
+	response responseState
 }
 
 type Response struct {
@@ -37,42 +54,109 @@ func (cd *CommandData) Option(name string) *dg.ApplicationCommandInteractionData
 	return nil
 }
 
+// Responds to the interaction. If it was deferred, the thinking message is replaced by the response; if a response was
+// already sent, this becomes a followup.
 func (cd *CommandData) Respond(r Response) error {
+	// the visibility decides the locale
+	setVisibility(&r)
+	cd.localize(&r)
+	cd.response.mu.Lock()
+	defer cd.response.mu.Unlock()
+	return cd.respond(&r)
+}
+
+// Returns the user who triggered the interaction. Outside of guilds there is no member, only the user.
+func (cd *CommandData) Invoker() *dg.User {
+	if cd.Member != nil {
+		return cd.Member.User
+	}
+	return cd.Interaction.User
+}
+
+// Returns the locale of messages everyone in the channel sees: the guild's preferred locale, or the user's own
+// outside of guilds
+func (cd *CommandData) PublicLocale() dg.Locale {
+	if cd.GuildLocale == nil {
+		return cd.Locale
+	}
+	return *cd.GuildLocale
+}
+
+// Sets the content from the response key
+func (cd *CommandData) localize(r *Response) {
 	if r.Key != "" {
-		locale := lo.Ternary(r.Flags&dg.MessageFlagsEphemeral != 0, cd.Locale, *cd.GuildLocale)
+		locale := cd.Locale
+		if r.Flags&dg.MessageFlagsEphemeral == 0 {
+			locale = cd.PublicLocale()
+		}
 		r.Content = i18n.Get(locale, r.Key, r.Vars)
 	}
-	if r.Public {
-		r.Flags &= ^dg.MessageFlagsEphemeral
-	} else {
-		r.Flags |= dg.MessageFlagsEphemeral
+}
+
+// Tells the user that their interaction failed, with the correlation ID of the error report. If the interaction was
+// already responded to, the message is sent as a followup instead.
+func (cd *CommandData) RespondError(correlationID string) error {
+	content := i18n.Get(cd.Locale, "base.error", &i18n.Vars{"id": correlationID})
+	err := cd.Respond(Response{InteractionResponseData: dg.InteractionResponseData{Content: content}})
+	if err != nil {
+		// the handler may have responded without going through Respond
+		_, err = cd.FollowupMessageCreate(cd.Interaction, false, &dg.WebhookParams{Content: content, Flags: dg.MessageFlagsEphemeral})
 	}
-	return cd.InteractionRespond(cd.Interaction, &dg.InteractionResponse{
-		Type: dg.InteractionResponseChannelMessageWithSource,
-		Data: &r.InteractionResponseData,
-	})
+	return err
 }
 
 type CommandHandler func(*CommandData) error
 
 type BotCommand struct {
 	dg.ApplicationCommand
+	Feature        *features.Feature           // only registered in guilds where the feature is enabled; nil for always
+	Contexts       []dg.InteractionContextType // where the command works besides guilds, e.g. PersonalContexts; inherited by subcommands
+	DeferPublic    bool                        // slow handlers are deferred publicly, for commands that respond publicly
+	Middlewares    []Middleware                // wrap the handler of this command and all of its subcommands
 	Subcommands    []*BotCommand
 	CommandHandler CommandHandler
+	Bind           *Binding                // declares the options and handler from a struct, see Bind; replaces Options and CommandHandler
+	Autocomplete   map[string]Autocomplete // suggests values for options while they are typed, by option name
 	subcommandMap  map[string]*BotCommand
 }
 
 /* Builds the subcommand tree. The command will be missing data for discord before Build() is called. */
 func (bc *BotCommand) Build() *BotCommand {
-	return bc.build("")
+	return bc.build("", nil)
+}
+
+// Returns the command at a full path like "quotes/find", or nil if there is none. Only works after calling build.
+func (bc *BotCommand) Lookup(path string) *BotCommand {
+	names := strings.Split(path, "/")
+	if names[0] != bc.Name {
+		return nil
+	}
+	cmd := bc
+	for _, name := range names[1:] {
+		if cmd = cmd.subcommandMap[name]; cmd == nil {
+			return nil
+		}
+	}
+	return cmd
 }
 
 func (bc *BotCommand) hasSubcommands() bool {
 	return len(bc.Subcommands) > 0
 }
 
-func (bc *BotCommand) build(prefix string) *BotCommand {
+func (bc *BotCommand) build(prefix string, inherited []Middleware) *BotCommand {
 	localizationKey := lo.Ternary(prefix == "", bc.Name, prefix+"/"+bc.Name)
+	middlewares := slices.Concat(inherited, bc.Middlewares)
+	if bc.Feature != nil {
+		middlewares = append(middlewares, RequireFeature(bc.Feature))
+	}
+	if bc.Bind != nil {
+		if len(bc.Options) > 0 || bc.CommandHandler != nil || bc.hasSubcommands() {
+			log.Panic().Str("command", bc.Name).Msg("Commands with bound options cannot also set options, a handler or subcommands.")
+		}
+		bc.Options = bc.Bind.options
+		bc.CommandHandler = bc.Bind.handler
+	}
 	if bc.hasSubcommands() {
 		if len(bc.Options) > 0 || bc.CommandHandler != nil {
 			log.Panic().Str("command", bc.Name).Msg("Cannot determine if this is a subcommand. If subcommands are defined, all handlers and options must be left unset.")
@@ -80,7 +164,8 @@ func (bc *BotCommand) build(prefix string) *BotCommand {
 		log.Debug().Str("command", bc.Name).Msg("Generating subcommands")
 		bc.subcommandMap = make(map[string]*BotCommand)
 		for _, sc := range bc.Subcommands {
-			bc.subcommandMap[sc.Name] = sc.build(localizationKey)
+			sc.Contexts = lo.CoalesceSliceOrEmpty(sc.Contexts, bc.Contexts)
+			bc.subcommandMap[sc.Name] = sc.build(localizationKey, middlewares)
 			bc.Options = append(bc.Options, sc.asSubcommandOption())
 		}
 		// Write the command handler if it's a subcommand
@@ -96,6 +181,9 @@ func (bc *BotCommand) build(prefix string) *BotCommand {
 				return fmt.Errorf("subcommand %s does not exist for parent command %s", scName, bc.Name)
 			}
 		}
+	} else {
+		// command rules are checked before anything else
+		bc.CommandHandler = chain(bc.dispatchAutocomplete(bc.CommandHandler), slices.Concat([]Middleware{restrict(localizationKey)}, middlewares))
 	}
 	bc.localizeCommand(localizationKey)
 	return bc
@@ -128,6 +216,71 @@ func (bc *BotCommand) localizeCommand(key string) {
 	}
 }
 
+/*
+	Returns the command to register in a guild, leaving out subcommands of disabled features. Returns false if the
+
+whole command is disabled. Only works after calling build.
+*/
+func (bc *BotCommand) ForGuild(guildID string) (*dg.ApplicationCommand, bool) {
+	options, ok := bc.enabledOptions(guildID)
+	if !ok {
+		return nil, false
+	}
+	cmd := bc.ApplicationCommand
+	cmd.Options = options
+	return &cmd, true
+}
+
+func (bc *BotCommand) enabledOptions(guildID string) ([]*dg.ApplicationCommandOption, bool) {
+	if bc.Feature != nil && !bc.Feature.Enabled(guildID) {
+		return nil, false
+	}
+	if !bc.hasSubcommands() {
+		return bc.Options, true
+	}
+	var options []*dg.ApplicationCommandOption
+	for _, sc := range bc.Subcommands {
+		if scOptions, ok := sc.enabledOptions(guildID); ok {
+			option := sc.asSubcommandOption()
+			option.Options = scOptions
+			options = append(options, option)
+		}
+	}
+	// a command without any subcommands left cannot be registered
+	return options, len(options) > 0
+}
+
+// Returns the command to register globally, for use outside guilds, with only the subcommands that declared Contexts.
+// Returns false if no part of the command works outside guilds. Only works after calling build.
+func (bc *BotCommand) Global() (*dg.ApplicationCommand, bool) {
+	options, contexts, ok := bc.globalOptions()
+	if !ok {
+		return nil, false
+	}
+	cmd := bc.ApplicationCommand
+	cmd.Options = options
+	cmd.Contexts = &contexts
+	cmd.IntegrationTypes = &[]dg.ApplicationIntegrationType{dg.ApplicationIntegrationGuildInstall, dg.ApplicationIntegrationUserInstall}
+	return &cmd, true
+}
+
+func (bc *BotCommand) globalOptions() ([]*dg.ApplicationCommandOption, []dg.InteractionContextType, bool) {
+	if !bc.hasSubcommands() {
+		return bc.Options, bc.Contexts, len(bc.Contexts) > 0
+	}
+	var options []*dg.ApplicationCommandOption
+	var contexts []dg.InteractionContextType
+	for _, sc := range bc.Subcommands {
+		if scOptions, scContexts, ok := sc.globalOptions(); ok {
+			option := sc.asSubcommandOption()
+			option.Options = scOptions
+			options = append(options, option)
+			contexts = lo.Union(contexts, scContexts)
+		}
+	}
+	return options, contexts, len(options) > 0
+}
+
 /* Returns the BotCommand's data, but encoded as a subcommand instead. Only works after calling calling build. */
 func (bc *BotCommand) asSubcommandOption() *dg.ApplicationCommandOption {
 	return &dg.ApplicationCommandOption{
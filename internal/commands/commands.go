@@ -3,6 +3,7 @@ package commands
 /** The actual command list available to the app */
 var Commands = []*BotCommand{
 	&echo,
+	&help,
 	&petpet,
 	&report,
 	&my,
@@ -13,6 +14,8 @@ var Commands = []*BotCommand{
 	&assignTempRole,
 	&assignRegularsRole,
 	&admin,
+	&tag,
+	&tags,
 
 	&flip,
 	&roll,
@@ -24,4 +27,12 @@ var Commands = []*BotCommand{
 	createTargetedCommand("pet"),
 	createTargetedCommand("tuck"),
 	createTargetedCommand("pour"),
+	&action,
+	&actions,
+}
+
+/** Handlers for buttons, select menus and modals, routed by custom ID prefix */
+var Components = []*Component{
+	&pageComponent,
+	&pageJumpComponent,
 }
@@ -0,0 +1,120 @@
+package commands
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	dg "github.com/bwmarrin/discordgo"
+	"github.com/rs/zerolog/log"
+)
+
+// Custom IDs are "prefix:arg1:arg2...". The prefix picks the handler and the arguments carry its state, since
+// components have to work without any server side state (e.g. after a restart).
+const customIDSeparator = ":"
+
+// Discord's limit on custom ID length
+const maxCustomIDLength = 100
+
+var customIDEscaper = strings.NewReplacer("%", "%25", customIDSeparator, "%3A")
+
+// Handles buttons, select menus and modal submits. Args are the arguments encoded in the custom ID.
+type ComponentHandler func(cd *CommandData, args []string) error
+
+type Component struct {
+	Prefix  string
+	Handler ComponentHandler
+}
+
+// Builds a custom ID that routes to this component with the given arguments. Arguments may contain any character,
+// but the whole ID must fit in discord's 100 character limit, so user input must be length limited.
+func (c *Component) CustomID(args ...string) string {
+	id, ok := buildCustomID(c.Prefix, args...)
+	if !ok {
+		log.Panic().Str("customID", id).Msg("Custom ID is longer than 100 characters")
+	}
+	return id
+}
+
+// Like CustomID, but returns false instead of panicking if the ID is too long
+func buildCustomID(prefix string, args ...string) (string, bool) {
+	parts := []string{prefix}
+	for _, arg := range args {
+		parts = append(parts, customIDEscaper.Replace(arg))
+	}
+	id := strings.Join(parts, customIDSeparator)
+	return id, len(id) <= maxCustomIDLength
+}
+
+// Splits a custom ID into the component prefix and its arguments
+func ParseCustomID(id string) (string, []string) {
+	parts := strings.Split(id, customIDSeparator)
+	args := make([]string, 0, len(parts)-1)
+	for _, part := range parts[1:] {
+		arg, err := url.PathUnescape(part)
+		if err != nil {
+			arg = part
+		}
+		args = append(args, arg)
+	}
+	return parts[0], args
+}
+
+// Acknowledges a component interaction by editing the message the component is attached to. If the interaction was
+// already acknowledged, the message is edited instead.
+func (cd *CommandData) UpdateMessage(r Response) error {
+	cd.localize(&r)
+	cd.response.mu.Lock()
+	defer cd.response.mu.Unlock()
+	if cd.response.status != responsePending {
+		_, err := cd.editOriginal(&r.InteractionResponseData)
+		return err
+	}
+	err := cd.InteractionRespond(cd.Interaction, &dg.InteractionResponse{
+		Type: dg.InteractionResponseUpdateMessage,
+		Data: &r.InteractionResponseData,
+	})
+	if err == nil {
+		cd.response.status = responseSent
+	}
+	return err
+}
+
+// Acknowledges a component interaction without changing the message yet. The message can be edited later with
+// UpdateMessage.
+func (cd *CommandData) DeferUpdate() error {
+	cd.response.mu.Lock()
+	defer cd.response.mu.Unlock()
+	if cd.response.status != responsePending {
+		return nil
+	}
+	return cd.deferUpdate()
+}
+
+// Responds with a modal. Modals cannot be shown once the interaction was acknowledged in any other way.
+func (cd *CommandData) RespondModal(data *dg.InteractionResponseData) error {
+	cd.response.mu.Lock()
+	defer cd.response.mu.Unlock()
+	if cd.response.status != responsePending {
+		return fmt.Errorf("cannot show modal %s after the interaction was acknowledged", data.CustomID)
+	}
+	err := cd.InteractionRespond(cd.Interaction, &dg.InteractionResponse{Type: dg.InteractionResponseModal, Data: data})
+	if err == nil {
+		cd.response.status = responseSent
+	}
+	return err
+}
+
+// Returns the value of a text input in a submitted modal, or "" if there is no such input
+func (cd *CommandData) ModalValue(inputID string) string {
+	for _, row := range cd.ModalSubmitData().Components {
+		if row, ok := row.(*dg.ActionsRow); ok {
+			for _, component := range row.Components {
+				if input, ok := component.(*dg.TextInput); ok && input.CustomID == inputID {
+					return input.Value
+				}
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,156 @@
+package commands
+
+import (
+	"fmt"
+	"snoozybot/internal/i18n"
+	"strings"
+
+	dg "github.com/bwmarrin/discordgo"
+	"github.com/samber/lo"
+)
+
+type helpOptions struct {
+	Command string `option:"command,maxlen=100"`
+}
+
+// Lists the commands the member can use in the guild, or explains one of them
+var help = BotCommand{
+	ApplicationCommand: dg.ApplicationCommand{Name: "help"},
+	Bind: Bind(func(cd *CommandData, opts *helpOptions) error {
+		if opts.Command == "" {
+			return helpPages.Respond(cd)
+		}
+		path, ok := commandRulePath(opts.Command)
+		if !ok {
+			return cd.Respond(Response{Key: "help.unknownCommand", Vars: &i18n.Vars{"command": opts.Command}})
+		}
+		// hidden commands are treated like missing ones, so help doesn't reveal them
+		entries := lo.Filter(helpEntries(cd), func(cmd *helpEntry, _ int) bool {
+			return cmd.path == path || strings.HasPrefix(cmd.path, path+"/")
+		})
+		if len(entries) == 0 {
+			return cd.Respond(Response{Key: "help.unknownCommand", Vars: &i18n.Vars{"command": opts.Command}})
+		}
+		embed := &dg.MessageEmbed{Title: "/" + helpName(cd, path)}
+		if len(entries) == 1 && entries[0].path == path {
+			embed.Description = localizedDescription(cd, entries[0].cmd.Description, entries[0].cmd.DescriptionLocalizations)
+			embed.Fields = lo.Map(entries[0].cmd.Options, func(opt *dg.ApplicationCommandOption, _ int) *dg.MessageEmbedField {
+				return helpOptionField(cd, opt)
+			})
+		} else {
+			embed.Fields = lo.Map(entries, func(cmd *helpEntry, _ int) *dg.MessageEmbedField {
+				return cmd.field(cd)
+			})
+		}
+		return cd.Respond(Response{InteractionResponseData: dg.InteractionResponseData{Embeds: []*dg.MessageEmbed{embed}}})
+	}),
+}
+
+var helpPages = newPaginator(Paginator{
+	Name:     "help",
+	PageSize: 10,
+	EmptyKey: "help.empty",
+	Count: func(cd *CommandData, args []string) (int64, error) {
+		return int64(len(helpEntries(cd))), nil
+	},
+	Page: func(cd *CommandData, args []string, offset int, limit int) ([]*dg.MessageEmbedField, error) {
+		entries := helpEntries(cd)
+		entries = entries[min(offset, len(entries)):min(offset+limit, len(entries))]
+		return lo.Map(entries, func(cmd *helpEntry, _ int) *dg.MessageEmbedField {
+			return cmd.field(cd)
+		}), nil
+	},
+})
+
+// A command that can be run, i.e. one without subcommands
+type helpEntry struct {
+	path string // e.g. "quotes/find"
+	cmd  *BotCommand
+}
+
+func (e *helpEntry) field(cd *CommandData) *dg.MessageEmbedField {
+	return &dg.MessageEmbedField{
+		Name:  "/" + helpName(cd, e.path),
+		Value: localizedDescription(cd, e.cmd.Description, e.cmd.DescriptionLocalizations),
+	}
+}
+
+// Returns the slash commands the member can see in the guild, in the order they are declared. Commands of disabled
+// features are left out, as are commands hidden from the member by DefaultMemberPermissions or blocked by the command
+// rules. Context menu commands are not typed by users, so they are left out too.
+func helpEntries(cd *CommandData) []*helpEntry {
+	var entries []*helpEntry
+	var walk func(cmd *BotCommand, path string)
+	walk = func(cmd *BotCommand, path string) {
+		if cmd.Feature != nil && !cmd.Feature.Enabled(cd.GuildID) {
+			return
+		}
+		if !rulesAllowCommand(cd, path) {
+			return
+		}
+		if !cmd.hasSubcommands() {
+			entries = append(entries, &helpEntry{path: path, cmd: cmd})
+			return
+		}
+		for _, sc := range cmd.Subcommands {
+			walk(sc, path+"/"+sc.Name)
+		}
+	}
+	for _, cmd := range commandTree {
+		if cmd.Type != 0 && cmd.Type != dg.ChatApplicationCommand {
+			continue
+		}
+		if !hasDefaultPermissions(cd, cmd.DefaultMemberPermissions) {
+			continue
+		}
+		walk(cmd, cmd.Name)
+	}
+	return entries
+}
+
+// Whether discord shows a command with the given DefaultMemberPermissions to the member. Discord treats 0 as
+// "administrators only", and administrators have every permission.
+func hasDefaultPermissions(cd *CommandData, permissions *int64) bool {
+	if permissions == nil {
+		return true
+	}
+	if cd.Member == nil {
+		return false
+	}
+	if cd.Member.Permissions&dg.PermissionAdministrator != 0 {
+		return true
+	}
+	return *permissions != 0 && cd.Member.Permissions&*permissions == *permissions
+}
+
+// Returns a command path as the user sees it, e.g. "quotes find" or "citas buscar"
+func helpName(cd *CommandData, path string) string {
+	names := strings.Split(path, "/")
+	localized := make([]string, len(names))
+	for i, name := range names {
+		localized[i] = name
+		if cmd := findCommand(strings.Join(names[:i+1], "/")); cmd != nil {
+			localized[i] = lo.CoalesceOrEmpty(lo.FromPtr(cmd.NameLocalizations)[cd.Locale], name)
+		}
+	}
+	return strings.Join(localized, " ")
+}
+
+func localizedDescription(cd *CommandData, fallback string, localizations *map[dg.Locale]string) string {
+	return lo.CoalesceOrEmpty(lo.FromPtr(localizations)[cd.Locale], fallback)
+}
+
+func helpOptionField(cd *CommandData, opt *dg.ApplicationCommandOption) *dg.MessageEmbedField {
+	name := lo.CoalesceOrEmpty(opt.NameLocalizations[cd.Locale], opt.Name)
+	if opt.Required {
+		name = i18n.Get(cd.Locale, "help.required", &i18n.Vars{"option": name})
+	}
+	value := localizedDescription(cd, opt.Description, &opt.DescriptionLocalizations)
+	if len(opt.Choices) > 0 {
+		choices := lo.Map(opt.Choices, func(choice *dg.ApplicationCommandOptionChoice, _ int) string {
+			return lo.CoalesceOrEmpty(choice.NameLocalizations[cd.Locale], choice.Name, fmt.Sprint(choice.Value))
+		})
+		value += "\n" + i18n.Get(cd.Locale, "help.choices", &i18n.Vars{"choices": strings.Join(choices, ", ")})
+	}
+	return &dg.MessageEmbedField{Name: name, Value: value}
+}
@@ -0,0 +1,102 @@
+package commands
+
+import (
+	"snoozybot/internal/cooldown"
+	"snoozybot/internal/features"
+	"time"
+
+	dg "github.com/bwmarrin/discordgo"
+)
+
+// Wraps a command handler, e.g. to check something before it runs. Middlewares of a command also apply to all of its
+// subcommands, outermost first.
+type Middleware func(next CommandHandler) CommandHandler
+
+// Builds a middleware that runs check before the handler. If check returns a response, it is sent instead of running
+// the handler. Autocomplete interactions cannot be answered with a message, so they get no suggestions instead.
+func Check(check func(cd *CommandData) *Response) Middleware {
+	return func(next CommandHandler) CommandHandler {
+		return func(cd *CommandData) error {
+			r := check(cd)
+			if r == nil {
+				return next(cd)
+			}
+			if cd.Type == dg.InteractionApplicationCommandAutocomplete {
+				return cd.InteractionRespond(cd.Interaction, &dg.InteractionResponse{
+					Type: dg.InteractionApplicationCommandAutocompleteResult,
+					Data: &dg.InteractionResponseData{Choices: []*dg.ApplicationCommandOptionChoice{}},
+				})
+			}
+			return cd.Respond(*r)
+		}
+	}
+}
+
+// Only runs the handler if the member has all of the given permissions in the channel. DefaultMemberPermissions only
+// hides commands by default and can be overridden by server admins, so commands that must not be used by everyone
+// should check this too.
+func RequirePermissions(permissions int64) Middleware {
+	return Check(func(cd *CommandData) *Response {
+		if cd.Member == nil || cd.Member.Permissions&permissions != permissions {
+			return &Response{Key: "base.missingPermissions"}
+		}
+		return nil
+	})
+}
+
+// Only runs the handler for interactions in a server
+var GuildOnly = Check(func(cd *CommandData) *Response {
+	if cd.GuildID == "" {
+		return &Response{Key: "base.guildOnly"}
+	}
+	return nil
+})
+
+// Only runs the handler if the feature is enabled in the server. Commands of disabled features are not registered,
+// but registration can lag behind config changes. Added automatically to commands with a Feature. Features are
+// configured per server, so commands that declared Contexts always run outside of servers.
+func RequireFeature(feature *features.Feature) Middleware {
+	return Check(func(cd *CommandData) *Response {
+		if cd.GuildID != "" && !feature.Enabled(cd.GuildID) {
+			return &Response{Key: "base.featureDisabled"}
+		}
+		return nil
+	})
+}
+
+// Only runs the handler if the member and channel are not on cooldown. Autocomplete does not count as an invocation,
+// and there are no cooldowns outside of servers.
+func Cooldown(cm *cooldown.CooldownManager) Middleware {
+	return Check(func(cd *CommandData) *Response {
+		if cd.Type == dg.InteractionApplicationCommandAutocomplete || cd.Member == nil || cm.Can(cd.GuildID, cd.ChannelID, cd.Member) {
+			return nil
+		}
+		return &Response{Key: "base.cooldown"}
+	})
+}
+
+// Logs who ran the command with which options, and how it went
+func AuditLog(next CommandHandler) CommandHandler {
+	return func(cd *CommandData) error {
+		if cd.Type == dg.InteractionApplicationCommandAutocomplete {
+			return next(cd)
+		}
+		start := time.Now()
+		err := next(cd)
+		cd.Log.Info().
+			Str("user", cd.Invoker().ID).
+			Str("channel", cd.ChannelID).
+			Any("options", cd.ApplicationCommandData().Options).
+			Dur("duration", time.Since(start)).
+			AnErr("error", err).
+			Msg("Audit: command invoked")
+		return err
+	}
+}
+
+func chain(handler CommandHandler, middlewares []Middleware) CommandHandler {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		handler = middlewares[i](handler)
+	}
+	return handler
+}
@@ -3,6 +3,7 @@ package commands
 import (
 	"errors"
 	"snoozybot/internal/database"
+	"snoozybot/internal/features"
 	"snoozybot/internal/i18n"
 
 	dg "github.com/bwmarrin/discordgo"
@@ -14,6 +15,8 @@ var myBedtime = BotCommand{
 	ApplicationCommand: dg.ApplicationCommand{
 		Name: "bedtime",
 	},
+	Feature:  features.Bedtime,
+	Contexts: PersonalContexts,
 	Subcommands: []*BotCommand{
 		&myBedtimeSet,
 		&myBedtimeGet,
@@ -34,7 +37,7 @@ var myBedtimeSet = BotCommand{
 		if err != nil {
 			return cd.Respond(Response{Key: "my.bedtime.set.invalid"})
 		}
-		user := database.User{UserID: cd.Interaction.Member.User.ID}
+		user := database.User{UserID: cd.Invoker().ID}
 		database.Database.Select("timezone").Take(&user)
 		if user.Timezone == nil {
 			return cd.Respond(Response{Key: "my.bedtime.set.timezone"})
@@ -53,7 +56,7 @@ var myBedtimeGet = BotCommand{
 		Name: "get",
 	},
 	CommandHandler: func(cd *CommandData) error {
-		user := database.User{UserID: cd.Interaction.Member.User.ID}
+		user := database.User{UserID: cd.Invoker().ID}
 		result := database.Database.Select("bedtime").Take(&user)
 		if user.Bedtime != nil {
 			return cd.Respond(Response{Key: "my.bedtime.get.success", Vars: &i18n.Vars{"time": user.Bedtime.String()}})
@@ -79,7 +82,7 @@ var myBedtimeClear = BotCommand{
 		Name: "clear",
 	},
 	CommandHandler: func(cd *CommandData) error {
-		user := database.User{UserID: cd.Interaction.Member.User.ID}
+		user := database.User{UserID: cd.Invoker().ID}
 		if res := database.Database.Model(&user).Select("bedtime").Update("timezone", nil); res.Error != nil {
 			return res.Error
 		}
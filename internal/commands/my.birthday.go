@@ -3,44 +3,33 @@ package commands
 import (
 	"snoozybot/internal/config"
 	"snoozybot/internal/database"
+	"snoozybot/internal/features"
 	"time"
 
 	dg "github.com/bwmarrin/discordgo"
-	"github.com/samber/lo"
 )
 
 var myBirthday = BotCommand{
 	ApplicationCommand: dg.ApplicationCommand{
 		Name: "birthday",
 	},
+	Feature: features.Birthday,
 	Subcommands: []*BotCommand{
 		&myBirthdaySet,
 		&myBirthdayClear,
 	},
 }
 
+type myBirthdaySetOptions struct {
+	Month int `option:"month,required,choices=1|2|3|4|5|6|7|8|9|10|11|12"`
+	Day   int `option:"day,required,min=1,max=31"`
+}
+
 var myBirthdaySet = BotCommand{
 	ApplicationCommand: dg.ApplicationCommand{
 		Name: "set",
-		Options: []*dg.ApplicationCommandOption{
-			{Name: "month", Type: dg.ApplicationCommandOptionInteger, Required: true, Choices: []*dg.ApplicationCommandOptionChoice{
-				{Name: "January", Value: 1},
-				{Name: "February", Value: 2},
-				{Name: "March", Value: 3},
-				{Name: "April", Value: 4},
-				{Name: "May", Value: 5},
-				{Name: "June", Value: 6},
-				{Name: "July", Value: 7},
-				{Name: "August", Value: 8},
-				{Name: "September", Value: 9},
-				{Name: "October", Value: 10},
-				{Name: "November", Value: 11},
-				{Name: "December", Value: 12},
-			}},
-			{Name: "day", Type: dg.ApplicationCommandOptionInteger, Required: true, MinValue: lo.ToPtr[float64](1), MaxValue: 31},
-		},
 	},
-	CommandHandler: func(cd *CommandData) error {
+	Bind: Bind(func(cd *CommandData, opts *myBirthdaySetOptions) error {
 		// check server has a birthday channel
 		birthdayChannel, err := config.ProfileBirthdayChannel.Get(cd.Interaction.GuildID).Value()
 		if err != nil || birthdayChannel == "" {
@@ -48,7 +37,7 @@ var myBirthdaySet = BotCommand{
 		}
 
 		// check user has timezone set
-		user := database.User{UserID: cd.Interaction.Member.User.ID}
+		user := database.User{UserID: cd.Invoker().ID}
 		if database.Database.Select("timezone").Take(&user); user.Timezone == nil {
 			return cd.Respond(Response{Key: "my/birthday/set.timezone"})
 		}
@@ -57,8 +46,7 @@ var myBirthdaySet = BotCommand{
 			return cd.Respond(Response{Key: "my/birthday/set.timezone"})
 		}
 
-		month := cd.Option("month").IntValue()
-		day := int(cd.Option("day").IntValue())
+		month, day := opts.Month, opts.Day
 
 		// check date is valid using a fixed leap year
 		d := time.Date(2000, time.Month(month), day, 0, 0, 0, 0, loc)
@@ -79,7 +67,7 @@ var myBirthdaySet = BotCommand{
 		// delete any existing scheduled task
 		cd.Log.Debug().Msg("Deleting existing birthday task")
 		database.Database.Where(&database.ScheduledTask{
-			GuildID: cd.Interaction.GuildID, TaskType: database.TaskTypeBirthday, UserID: cd.Interaction.Member.User.ID,
+			GuildID: cd.Interaction.GuildID, TaskType: database.TaskTypeBirthday, UserID: cd.Invoker().ID,
 		}).Delete(&database.ScheduledTask{})
 
 		// create the scheduled task
@@ -87,14 +75,14 @@ var myBirthdaySet = BotCommand{
 			GuildID:      cd.Interaction.GuildID,
 			TaskType:     database.TaskTypeBirthday,
 			ProcessAfter: nextBirthday,
-			UserID:       cd.Interaction.Member.User.ID,
+			UserID:       cd.Invoker().ID,
 		}); res.Error != nil {
 			return res.Error
 		}
 
 		cd.Log.Info().Msg("Created birthday task")
 		return cd.Respond(Response{Key: "my/birthday/set.success"})
-	},
+	}),
 }
 
 var myBirthdayClear = BotCommand{
@@ -103,7 +91,7 @@ var myBirthdayClear = BotCommand{
 	},
 	CommandHandler: func(cd *CommandData) error {
 		database.Database.Where(&database.ScheduledTask{
-			GuildID: cd.Interaction.GuildID, TaskType: database.TaskTypeBirthday, UserID: cd.Interaction.Member.User.ID,
+			GuildID: cd.Interaction.GuildID, TaskType: database.TaskTypeBirthday, UserID: cd.Invoker().ID,
 		}).Delete(&database.ScheduledTask{})
 		cd.Log.Info().Msg("Cleared birthday task")
 		return cd.Respond(Response{Key: "my/birthday/clear.success"})
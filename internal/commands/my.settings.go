@@ -11,6 +11,7 @@ import (
 
 var mySettings = BotCommand{
 	ApplicationCommand: dg.ApplicationCommand{Name: "settings"},
+	Contexts:           PersonalContexts,
 	Subcommands: []*BotCommand{
 		&mySettingsSuppressMentions,
 	},
@@ -29,11 +30,11 @@ var mySettingsSuppressMentions = BotCommand{
 		result := database.Database.Clauses(clause.OnConflict{
 			Columns:   []clause.Column{{Name: "user_id"}},
 			DoUpdates: clause.AssignmentColumns([]string{"suppress_mentions"}),
-		}).Create(&database.User{UserID: cd.Member.User.ID, SuppressMentions: suppress})
+		}).Create(&database.User{UserID: cd.Invoker().ID, SuppressMentions: suppress})
 		if result.Error != nil {
 			return result.Error
 		}
-		database.UserCache.Remove(cd.Member.User.ID)
+		database.UserCache.Remove(cd.Invoker().ID)
 		key := lo.Ternary(suppress, "my/settings/mentions.set", "my/settings/mentions.unset")
 		return cd.Respond(Response{Key: key, Vars: &i18n.Vars{"suppress": suppress}})
 	},
@@ -0,0 +1,318 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"snoozybot/internal/i18n"
+	"strconv"
+	"strings"
+
+	dg "github.com/bwmarrin/discordgo"
+	"github.com/rs/zerolog/log"
+	"github.com/samber/lo"
+)
+
+// Options of a command declared as a struct, with one field per option. The field type picks the option type:
+//
+//	string, int, int64, float64, bool  -> string, integer, number and boolean options (also as pointers)
+//	*dg.User, *dg.Member               -> user option, resolved to the user or the guild member
+//	*dg.Channel, *dg.Role              -> channel and role options
+//	*dg.MessageAttachment              -> attachment option
+//
+// The `option` tag gives the option name followed by comma separated flags:
+//
+//	required, autocomplete, min=N, max=N (numbers), minlen=N, maxlen=N (strings), choices=a|b|c
+//
+// e.g. `option:"day,required,min=1,max=31"`. Names, descriptions and choice names are localized like any other
+// option. Missing optional options are left at their zero value, so use a pointer (e.g. *bool) to tell a missing
+// option apart from a zero value.
+type Binding struct {
+	options []*dg.ApplicationCommandOption
+	handler CommandHandler
+}
+
+// Thrown when an option value fails validation. The user is told which option is wrong.
+type InvalidOptionError struct {
+	Option string
+	Reason string
+}
+
+func (e *InvalidOptionError) Error() string {
+	return fmt.Sprintf("invalid value for option %s: %s", e.Option, e.Reason)
+}
+
+type boundOption struct {
+	field  int
+	option *dg.ApplicationCommandOption
+}
+
+var (
+	typeUser       = reflect.TypeFor[*dg.User]()
+	typeMember     = reflect.TypeFor[*dg.Member]()
+	typeChannel    = reflect.TypeFor[*dg.Channel]()
+	typeRole       = reflect.TypeFor[*dg.Role]()
+	typeAttachment = reflect.TypeFor[*dg.MessageAttachment]()
+)
+
+// Declares the command options from the fields of T. The handler is called with the options decoded, validated and
+// resolved from the interaction. For autocomplete interactions, only string, number and boolean options are decoded,
+// without validation, since the user has not finished typing.
+func Bind[T any](handler func(cd *CommandData, opts *T) error) *Binding {
+	bound := parseOptions(reflect.TypeFor[T]())
+	return &Binding{
+		options: lo.Map(bound, func(b boundOption, _ int) *dg.ApplicationCommandOption { return b.option }),
+		handler: func(cd *CommandData) error {
+			var opts T
+			err := decodeOptions(cd, reflect.ValueOf(&opts).Elem(), bound, cd.Type != dg.InteractionApplicationCommandAutocomplete)
+			var invalid *InvalidOptionError
+			if errors.As(err, &invalid) {
+				cd.Log.Info().Err(err).Msg("Invalid option value")
+				return cd.Respond(Response{Key: "base.invalidOption", Vars: &i18n.Vars{"option": invalid.Option}})
+			} else if err != nil {
+				return err
+			}
+			return handler(cd, &opts)
+		},
+	}
+}
+
+func parseOptions(t reflect.Type) []boundOption {
+	if t.Kind() != reflect.Struct {
+		log.Panic().Str("type", t.String()).Msg("Command options must be a struct")
+	}
+	var bound []boundOption
+	optional := false
+	for i := range t.NumField() {
+		field := t.Field(i)
+		tag, ok := field.Tag.Lookup("option")
+		if !ok {
+			continue
+		}
+		option := parseOption(field, tag)
+		if option.Required && optional {
+			log.Panic().Str("type", t.String()).Str("option", option.Name).Msg("Required options must come before optional ones")
+		}
+		optional = !option.Required
+		bound = append(bound, boundOption{field: i, option: option})
+	}
+	return bound
+}
+
+func parseOption(field reflect.StructField, tag string) *dg.ApplicationCommandOption {
+	flags := strings.Split(tag, ",")
+	option := &dg.ApplicationCommandOption{Name: flags[0], Type: optionType(field)}
+	fail := func(flag string) {
+		log.Panic().Str("field", field.Name).Str("flag", flag).Msg("Invalid option tag")
+	}
+	for _, flag := range flags[1:] {
+		key, value, _ := strings.Cut(flag, "=")
+		switch key {
+		case "required":
+			option.Required = true
+		case "autocomplete":
+			option.Autocomplete = true
+		case "min":
+			min, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				fail(flag)
+			}
+			option.MinValue = &min
+		case "max":
+			max, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				fail(flag)
+			}
+			option.MaxValue = max
+		case "minlen":
+			minLength, err := strconv.Atoi(value)
+			if err != nil {
+				fail(flag)
+			}
+			option.MinLength = &minLength
+		case "maxlen":
+			maxLength, err := strconv.Atoi(value)
+			if err != nil {
+				fail(flag)
+			}
+			option.MaxLength = maxLength
+		case "choices":
+			for _, choice := range strings.Split(value, "|") {
+				parsed, err := parseScalar(field.Type, choice)
+				if err != nil {
+					fail(flag)
+				}
+				// the name is localized in build, from options.<name>.choices.<value>
+				option.Choices = append(option.Choices, &dg.ApplicationCommandOptionChoice{Value: parsed})
+			}
+		default:
+			fail(flag)
+		}
+	}
+	return option
+}
+
+func optionType(field reflect.StructField) dg.ApplicationCommandOptionType {
+	switch field.Type {
+	case typeUser, typeMember:
+		return dg.ApplicationCommandOptionUser
+	case typeChannel:
+		return dg.ApplicationCommandOptionChannel
+	case typeRole:
+		return dg.ApplicationCommandOptionRole
+	case typeAttachment:
+		return dg.ApplicationCommandOptionAttachment
+	}
+	switch scalarType(field.Type).Kind() {
+	case reflect.String:
+		return dg.ApplicationCommandOptionString
+	case reflect.Int, reflect.Int64:
+		return dg.ApplicationCommandOptionInteger
+	case reflect.Float64:
+		return dg.ApplicationCommandOptionNumber
+	case reflect.Bool:
+		return dg.ApplicationCommandOptionBoolean
+	}
+	log.Panic().Str("field", field.Name).Str("type", field.Type.String()).Msg("Unsupported option type")
+	return 0
+}
+
+// Returns the element type of pointers to scalars, e.g. bool for *bool
+func scalarType(t reflect.Type) reflect.Type {
+	if t.Kind() == reflect.Pointer && t.Elem().Kind() != reflect.Struct {
+		return t.Elem()
+	}
+	return t
+}
+
+func parseScalar(t reflect.Type, s string) (any, error) {
+	switch scalarType(t).Kind() {
+	case reflect.String:
+		return s, nil
+	case reflect.Int, reflect.Int64:
+		return strconv.Atoi(s)
+	case reflect.Float64:
+		return strconv.ParseFloat(s, 64)
+	}
+	return nil, fmt.Errorf("choices are not supported for %s", t)
+}
+
+func decodeOptions(cd *CommandData, opts reflect.Value, bound []boundOption, validate bool) error {
+	data := cd.ApplicationCommandData()
+	resolved := lo.FromPtrOr(data.Resolved, dg.ApplicationCommandInteractionDataResolved{})
+	for _, b := range bound {
+		opt := cd.Option(b.option.Name)
+		if opt == nil || opt.Value == nil {
+			if validate && b.option.Required {
+				return &InvalidOptionError{Option: b.option.Name, Reason: "missing"}
+			}
+			continue
+		}
+		field := opts.Field(b.field)
+		t := scalarType(field.Type())
+		if !validate && t.Kind() == reflect.Pointer {
+			// autocomplete interactions come without resolved users, channels, etc.
+			continue
+		}
+		value, err := resolveOption(cd, t, opt, &resolved)
+		if err != nil {
+			return err
+		}
+		if validate {
+			if err := validateOption(b.option, value); err != nil {
+				return err
+			}
+		}
+		converted := reflect.ValueOf(value).Convert(t)
+		if t != field.Type() {
+			pointer := reflect.New(t)
+			pointer.Elem().Set(converted)
+			converted = pointer
+		}
+		field.Set(converted)
+	}
+	return nil
+}
+
+// Converts an option value to the field type, resolving users, members, channels, roles and attachments from the
+// interaction, or from the API if discord did not include them.
+func resolveOption(cd *CommandData, t reflect.Type, opt *dg.ApplicationCommandInteractionDataOption, resolved *dg.ApplicationCommandInteractionDataResolved) (any, error) {
+	id := fmt.Sprint(opt.Value)
+	switch t {
+	case typeUser:
+		if user, ok := resolved.Users[id]; ok {
+			return user, nil
+		}
+		return cd.User(id)
+	case typeMember:
+		if member, ok := resolved.Members[id]; ok {
+			// resolved members are partial; the user is resolved separately
+			member.User = lo.CoalesceOrEmpty(member.User, resolved.Users[id])
+			member.GuildID = cd.GuildID
+			if member.User != nil {
+				return member, nil
+			}
+		}
+		member, err := cd.GuildMember(cd.GuildID, id)
+		if err != nil {
+			return nil, &InvalidOptionError{Option: opt.Name, Reason: "not a member of this server"}
+		}
+		return member, nil
+	case typeChannel:
+		if channel, ok := resolved.Channels[id]; ok {
+			return channel, nil
+		}
+		return cd.Channel(id)
+	case typeRole:
+		if role, ok := resolved.Roles[id]; ok {
+			return role, nil
+		}
+		return cd.State.Role(cd.GuildID, id)
+	case typeAttachment:
+		if attachment, ok := resolved.Attachments[id]; ok {
+			return attachment, nil
+		}
+		return nil, &InvalidOptionError{Option: opt.Name, Reason: "attachment not found"}
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return opt.StringValue(), nil
+	case reflect.Int, reflect.Int64:
+		return opt.IntValue(), nil
+	case reflect.Float64:
+		return opt.FloatValue(), nil
+	case reflect.Bool:
+		return opt.BoolValue(), nil
+	}
+	return nil, fmt.Errorf("unsupported option type %s", t)
+}
+
+func validateOption(option *dg.ApplicationCommandOption, value any) error {
+	invalid := func(reason string, args ...any) error {
+		return &InvalidOptionError{Option: option.Name, Reason: fmt.Sprintf(reason, args...)}
+	}
+	switch v := value.(type) {
+	case string:
+		if option.MinLength != nil && len([]rune(v)) < *option.MinLength {
+			return invalid("shorter than %d characters", *option.MinLength)
+		}
+		if option.MaxLength > 0 && len([]rune(v)) > option.MaxLength {
+			return invalid("longer than %d characters", option.MaxLength)
+		}
+	case int64, float64:
+		number := reflect.ValueOf(v).Convert(reflect.TypeFor[float64]()).Float()
+		if option.MinValue != nil && number < *option.MinValue {
+			return invalid("less than %v", *option.MinValue)
+		}
+		if option.MaxValue != 0 && number > option.MaxValue {
+			return invalid("more than %v", option.MaxValue)
+		}
+	}
+	if len(option.Choices) > 0 && !slices.ContainsFunc(option.Choices, func(choice *dg.ApplicationCommandOptionChoice) bool {
+		return fmt.Sprint(choice.Value) == fmt.Sprint(value)
+	}) {
+		return invalid("not one of the choices")
+	}
+	return nil
+}
@@ -0,0 +1,179 @@
+package commands
+
+import (
+	"fmt"
+	"snoozybot/internal/i18n"
+	"strconv"
+	"strings"
+
+	dg "github.com/bwmarrin/discordgo"
+	"github.com/samber/lo"
+)
+
+// A list shown one page at a time as an embed, with buttons to move between pages. The page and the arguments of the
+// list (e.g. search filters) are kept in the custom IDs of the buttons, and only the user who ran the command can
+// turn the pages.
+type Paginator struct {
+	Name     string // identifies the list in custom IDs; keep it short
+	PageSize int
+	Public   bool
+	EmptyKey string // response key used when the list has no items
+	// Counts the items of the list
+	Count func(cd *CommandData, args []string) (int64, error)
+	// Returns the items of a page as embed fields. The query must have a stable order (e.g. ending with the primary key)
+	// so that pages neither overlap nor skip items.
+	Page func(cd *CommandData, args []string, offset int, limit int) ([]*dg.MessageEmbedField, error)
+}
+
+// Custom ID prefixes of the paginator buttons
+const (
+	pagePrefix     = "page"
+	pageJumpPrefix = "pagejump"
+)
+
+var paginators = map[string]*Paginator{}
+
+func newPaginator(p Paginator) *Paginator {
+	paginators[p.Name] = &p
+	return &p
+}
+
+var pageComponent = Component{
+	Prefix: pagePrefix,
+	Handler: func(cd *CommandData, args []string) error {
+		p, page, args, err := parsePageArgs(args, true)
+		if err != nil {
+			return err
+		}
+		if !isInvoker(cd) {
+			return cd.Respond(Response{Key: "paginator.notInvoker"})
+		}
+		return p.update(cd, page, args)
+	},
+}
+
+// Asks for a page number in a modal, then shows that page once the modal is submitted
+var pageJumpComponent = Component{
+	Prefix: pageJumpPrefix,
+	Handler: func(cd *CommandData, args []string) error {
+		p, _, args, err := parsePageArgs(args, false)
+		if err != nil {
+			return err
+		}
+		if !isInvoker(cd) {
+			return cd.Respond(Response{Key: "paginator.notInvoker"})
+		}
+		if cd.Type == dg.InteractionModalSubmit {
+			page, err := strconv.Atoi(strings.TrimSpace(cd.ModalValue("page")))
+			if err != nil {
+				return cd.Respond(Response{Key: "paginator.invalidPage"})
+			}
+			return p.update(cd, page-1, args)
+		}
+		locale := p.locale(cd)
+		return cd.RespondModal(&dg.InteractionResponseData{
+			CustomID: cd.MessageComponentData().CustomID,
+			Title:    i18n.Get(locale, "paginator.jumpTitle"),
+			Components: []dg.MessageComponent{dg.ActionsRow{Components: []dg.MessageComponent{dg.TextInput{
+				CustomID:  "page",
+				Label:     i18n.Get(locale, "paginator.jumpLabel"),
+				Style:     dg.TextInputShort,
+				Required:  true,
+				MaxLength: 6,
+			}}}},
+		})
+	},
+}
+
+// Custom ID arguments are the paginator name, the page (only for page buttons) and the list arguments
+func parsePageArgs(args []string, hasPage bool) (*Paginator, int, []string, error) {
+	if len(args) < lo.Ternary(hasPage, 2, 1) {
+		return nil, 0, nil, fmt.Errorf("malformed page custom ID arguments %v", args)
+	}
+	p, ok := paginators[args[0]]
+	if !ok {
+		return nil, 0, nil, fmt.Errorf("paginator %s does not exist", args[0])
+	}
+	if !hasPage {
+		return p, 0, args[1:], nil
+	}
+	page, err := strconv.Atoi(args[1])
+	if err != nil {
+		return nil, 0, nil, fmt.Errorf("malformed page number %s: %w", args[1], err)
+	}
+	return p, page, args[2:], nil
+}
+
+// Whether the user of a component interaction is the one who ran the command the message belongs to. False if that
+// can't be told, so nobody else can page through an ephemeral list.
+func isInvoker(cd *CommandData) bool {
+	if cd.Message == nil || cd.Message.InteractionMetadata == nil || cd.Message.InteractionMetadata.User == nil {
+		return false
+	}
+	return cd.Message.InteractionMetadata.User.ID == cd.Invoker().ID
+}
+
+// Responds with the first page of the list
+func (p *Paginator) Respond(cd *CommandData, args ...string) error {
+	data, err := p.render(cd, 0, args)
+	if err != nil {
+		return err
+	} else if data == nil {
+		return cd.Respond(Response{Key: p.EmptyKey, Public: p.Public})
+	}
+	return cd.Respond(Response{InteractionResponseData: *data, Public: p.Public})
+}
+
+func (p *Paginator) update(cd *CommandData, page int, args []string) error {
+	data, err := p.render(cd, page, args)
+	if err != nil {
+		return err
+	} else if data == nil {
+		// everything was deleted since the list was shown
+		return cd.UpdateMessage(Response{Key: p.EmptyKey, InteractionResponseData: dg.InteractionResponseData{
+			Embeds:     []*dg.MessageEmbed{},
+			Components: []dg.MessageComponent{},
+		}})
+	}
+	return cd.UpdateMessage(Response{InteractionResponseData: *data})
+}
+
+// Renders a page, clamped to the pages that exist. Returns nil if the list is empty.
+func (p *Paginator) render(cd *CommandData, page int, args []string) (*dg.InteractionResponseData, error) {
+	total, err := p.Count(cd, args)
+	if err != nil || total == 0 {
+		return nil, err
+	}
+	pages := int((total + int64(p.PageSize) - 1) / int64(p.PageSize))
+	page = max(0, min(page, pages-1))
+	fields, err := p.Page(cd, args, page*p.PageSize, p.PageSize)
+	if err != nil {
+		return nil, err
+	}
+	locale := p.locale(cd)
+	data := &dg.InteractionResponseData{Embeds: []*dg.MessageEmbed{{
+		Fields: fields,
+		Footer: &dg.MessageEmbedFooter{Text: i18n.Get(locale, "paginator.page", &i18n.Vars{"page": page + 1, "pages": pages})},
+	}}}
+	if pages == 1 {
+		return data, nil
+	}
+	previousID, ok1 := buildCustomID(pagePrefix, append([]string{p.Name, strconv.Itoa(page - 1)}, args...)...)
+	nextID, ok2 := buildCustomID(pagePrefix, append([]string{p.Name, strconv.Itoa(page + 1)}, args...)...)
+	jumpID, ok3 := buildCustomID(pageJumpPrefix, append([]string{p.Name}, args...)...)
+	if !ok1 || !ok2 || !ok3 {
+		// long arguments can push the state over discord's limit; the first page is still useful on its own
+		cd.Log.Warn().Strs("args", args).Msg("Paginator state does not fit in a custom ID. Showing the page without buttons.")
+		return data, nil
+	}
+	data.Components = []dg.MessageComponent{dg.ActionsRow{Components: []dg.MessageComponent{
+		dg.Button{CustomID: previousID, Label: i18n.Get(locale, "paginator.previous"), Style: dg.SecondaryButton, Disabled: page == 0},
+		dg.Button{CustomID: nextID, Label: i18n.Get(locale, "paginator.next"), Style: dg.SecondaryButton, Disabled: page == pages-1},
+		dg.Button{CustomID: jumpID, Label: i18n.Get(locale, "paginator.jump"), Style: dg.SecondaryButton},
+	}}}
+	return data, nil
+}
+
+func (p *Paginator) locale(cd *CommandData) dg.Locale {
+	return lo.Ternary(p.Public, cd.PublicLocale(), cd.Locale)
+}
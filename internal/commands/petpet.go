@@ -3,6 +3,7 @@ package commands
 import (
 	"net/http"
 	"net/url"
+	"snoozybot/internal/features"
 	"strings"
 
 	dg "github.com/bwmarrin/discordgo"
@@ -15,6 +16,8 @@ var petpet = BotCommand{
 			{Name: "user", Type: dg.ApplicationCommandOptionUser, Required: true},
 		},
 	},
+	Feature:     features.Fun,
+	DeferPublic: true,
 	CommandHandler: func(cd *CommandData) error {
 		user := cd.Option("user").UserValue(cd.Session)
 		pngUrl := strings.Replace(user.AvatarURL(""), ".webp", ".png", 1)
@@ -24,15 +27,7 @@ var petpet = BotCommand{
 			// can't really tell the user about this; it's a backend issue...
 			return err
 		}
-		return cd.Respond(Response{
-			Public: true,
-			InteractionResponseData: dg.InteractionResponseData{
-				Files: []*dg.File{{
-					Name:        user.Username + "_petpet.gif",
-					ContentType: resp.Header.Get("Content-Type"),
-					Reader:      resp.Body,
-				}},
-			},
-		})
+		defer resp.Body.Close()
+		return cd.Respond(Response{Public: true}.WithFile(user.Username+"_petpet.gif", resp.Header.Get("Content-Type"), resp.Body))
 	},
 }
@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"snoozybot/internal/database"
+	"snoozybot/internal/features"
 	"snoozybot/internal/i18n"
 
 	dg "github.com/bwmarrin/discordgo"
@@ -20,6 +21,8 @@ var quote = BotCommand{
 			{Name: "user", Type: dg.ApplicationCommandOptionUser, Required: true},
 		},
 	},
+	Feature:     features.Quotes,
+	DeferPublic: true,
 	CommandHandler: func(cd *CommandData) error {
 		target, err := cd.GuildMember(cd.GuildID, cd.Option("user").UserValue(cd.Session).ID)
 		if err != nil {
@@ -42,6 +45,7 @@ var quote = BotCommand{
 
 var quotes = BotCommand{
 	ApplicationCommand: dg.ApplicationCommand{Name: "quotes", DefaultMemberPermissions: &CommandPermissionModeratorOnly},
+	Feature:            features.Quotes,
 	Subcommands: []*BotCommand{
 		&quotesGet,
 		&quotesAdd,
@@ -57,10 +61,12 @@ var quotesGet = BotCommand{
 			{Name: "id", Type: dg.ApplicationCommandOptionInteger, Required: true},
 		},
 	},
+	DeferPublic:  true,
+	Autocomplete: map[string]Autocomplete{"id": autocompleteQuotes},
 	CommandHandler: func(cd *CommandData) error {
 		id := cd.Option("id").UintValue()
-		quote := database.Quote{ID: uint(id)}
-		if res := database.Database.Take(&quote); res.Error != nil {
+		var quote database.Quote
+		if res := database.Database.Where(&database.Quote{ID: uint(id), GuildID: cd.GuildID}).Take(&quote); res.Error != nil {
 			if errors.Is(res.Error, gorm.ErrRecordNotFound) {
 				return cd.Respond(Response{Key: "quote.missing"})
 			}
@@ -98,6 +104,7 @@ var quotesAddContextMenu = BotCommand{
 		Name: "Add Quote",
 		Type: dg.MessageApplicationCommand,
 	},
+	Feature: features.Quotes,
 	CommandHandler: func(cd *CommandData) error {
 		message := cd.ApplicationCommandData().Resolved.Messages[cd.ApplicationCommandData().TargetID]
 		return _addQuote(cd, message.Author, message.Content)
@@ -109,7 +116,7 @@ func _addQuote(cd *CommandData, user *dg.User, content string) error {
 		return cd.Respond(Response{Key: "quotes/add.botTarget"})
 	}
 	digest := _computeDigest(content)
-	quote := database.Quote{GuildID: cd.GuildID, UserID: user.ID, AddedBy: cd.Member.User.ID, Content: content, ContentDigest: digest}
+	quote := database.Quote{GuildID: cd.GuildID, UserID: user.ID, AddedBy: cd.Invoker().ID, Content: content, ContentDigest: digest}
 	if result := database.Database.Create(&quote); result.Error != nil {
 		if errors.Is(result.Error, gorm.ErrDuplicatedKey) {
 			return cd.Respond(Response{Key: "quotes/add.duplicate"})
@@ -143,72 +150,79 @@ var quotesDelete = BotCommand{
 			{Name: "id", Type: dg.ApplicationCommandOptionInteger, Required: true},
 		},
 	},
+	Autocomplete: map[string]Autocomplete{"id": autocompleteQuotes},
 	CommandHandler: func(cd *CommandData) error {
 		id := cd.Option("id").UintValue()
 		quote := database.Quote{ID: uint(id)}
-		if res := database.Database.Delete(&quote); res.Error != nil {
-			if errors.Is(res.Error, gorm.ErrRecordNotFound) {
-				return cd.Respond(Response{Key: "quotes/delete.missing"})
-			}
+		res := database.Database.Where(&database.Quote{GuildID: cd.GuildID}).Delete(&quote)
+		if res.Error != nil {
 			return res.Error
 		}
+		// quotes of other guilds are as missing as deleted ones
+		if res.RowsAffected == 0 {
+			return cd.Respond(Response{Key: "quotes/delete.missing"})
+		}
 		cd.Log.Info().Uint("quote", quote.ID).Msg("Deleted quote.")
 		return cd.Respond(Response{Key: "quotes/delete.success"})
 	},
 }
 
+type quotesFindOptions struct {
+	TextSearch string   `option:"text_search,maxlen=40"`
+	User       *dg.User `option:"user"`
+}
+
 var quotesFind = BotCommand{
 	ApplicationCommand: dg.ApplicationCommand{
 		Name: "find",
-		Options: []*dg.ApplicationCommandOption{
-			{Name: "text_search", Type: dg.ApplicationCommandOptionString},
-			{Name: "user", Type: dg.ApplicationCommandOptionUser},
-		},
 	},
-	CommandHandler: func(cd *CommandData) error {
-		text, _ := lo.TryOr(func() (string, error) {
-			return cd.Option("text_search").StringValue(), nil
-		}, "")
-		user, _ := lo.TryOr(func() (*dg.User, error) {
-			return cd.Option("user").UserValue(cd.Session), nil
-		}, nil)
-		var quotes []*database.Quote
-		query := database.Database.Limit(11)
-		if user != nil {
-			query = query.Where(&database.Quote{UserID: user.ID})
-		}
-		if text != "" {
-			query = query.Where("content LIKE ?", fmt.Sprintf("%%%s%%", text))
+	Bind: Bind(func(cd *CommandData, opts *quotesFindOptions) error {
+		userID := ""
+		if opts.User != nil {
+			userID = opts.User.ID
 		}
-		if res := query.Find(&quotes); res.Error != nil {
-			return res.Error
-		} else if res.RowsAffected == 0 {
-			return cd.Respond(Response{Key: "quotes/find.empty"})
-		}
-		embed := &dg.MessageEmbed{
-			Fields: lo.Map(lo.Slice(quotes, 0, 10), func(q *database.Quote, _ int) *dg.MessageEmbedField {
-				var userName string
-				if user, err := cd.GuildMember(cd.GuildID, q.UserID); err == nil {
-					userName = user.DisplayName()
-				} else {
-					cd.Log.Warn().Str("user", q.UserID).Err(err).Msg("Failed to get user for quote")
-					userName = "Unknown User"
-				}
-				return &dg.MessageEmbedField{
-					Name:  fmt.Sprintf("[#%d] %s", q.ID, userName),
-					Value: q.Content,
-				}
-			}),
-		}
-		if len(quotes) > 10 {
-			embed.Footer = &dg.MessageEmbedFooter{Text: i18n.Get(*cd.GuildLocale, "responses.quotes/find.hasMore")}
+		return quotesFindPages.Respond(cd, opts.TextSearch, userID)
+	}),
+}
+
+// Arguments are the text search and the user ID, either of which may be empty
+var quotesFindPages = newPaginator(Paginator{
+	Name:     "quotes",
+	PageSize: 10,
+	EmptyKey: "quotes/find.empty",
+	Count: func(cd *CommandData, args []string) (int64, error) {
+		var count int64
+		return count, quotesFindQuery(cd, args).Model(&database.Quote{}).Count(&count).Error
+	},
+	Page: func(cd *CommandData, args []string, offset int, limit int) ([]*dg.MessageEmbedField, error) {
+		var quotes []*database.Quote
+		if res := quotesFindQuery(cd, args).Order("id").Offset(offset).Limit(limit).Find(&quotes); res.Error != nil {
+			return nil, res.Error
 		}
-		return cd.InteractionRespond(cd.Interaction, &dg.InteractionResponse{
-			Type: dg.InteractionResponseChannelMessageWithSource,
-			Data: &dg.InteractionResponseData{
-				Embeds: []*dg.MessageEmbed{embed},
-				Flags:  dg.MessageFlagsEphemeral,
-			},
-		})
+		return lo.Map(quotes, func(q *database.Quote, _ int) *dg.MessageEmbedField {
+			var userName string
+			if user, err := cd.GuildMember(cd.GuildID, q.UserID); err == nil {
+				userName = user.DisplayName()
+			} else {
+				cd.Log.Warn().Str("user", q.UserID).Err(err).Msg("Failed to get user for quote")
+				userName = "Unknown User"
+			}
+			return &dg.MessageEmbedField{
+				Name:  fmt.Sprintf("[#%d] %s", q.ID, userName),
+				Value: q.Content,
+			}
+		}), nil
 	},
+})
+
+func quotesFindQuery(cd *CommandData, args []string) *gorm.DB {
+	text, userID := args[0], args[1]
+	query := database.Database.Where(&database.Quote{GuildID: cd.GuildID})
+	if userID != "" {
+		query = query.Where(&database.Quote{UserID: userID})
+	}
+	if text != "" {
+		query = query.Where("content LIKE ?", fmt.Sprintf("%%%s%%", text))
+	}
+	return query
 }
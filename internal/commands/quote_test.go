@@ -0,0 +1,84 @@
+package commands_test
+
+import (
+	"crypto/md5"
+	"fmt"
+	"snoozybot/internal/config"
+	"snoozybot/internal/database"
+	"snoozybot/internal/replay"
+	"strconv"
+	"strings"
+	"testing"
+
+	dg "github.com/bwmarrin/discordgo"
+)
+
+// Stores a quote of the user in the guild, deleted after the test
+func addQuote(t *testing.T, guildID, userID, content string) *database.Quote {
+	t.Helper()
+	quote := &database.Quote{GuildID: guildID, UserID: userID, AddedBy: userID, Content: content, ContentDigest: fmt.Sprintf("%x", md5.Sum([]byte(content)))}
+	if err := database.Database.Create(quote).Error; err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { database.Database.Delete(quote) })
+	return quote
+}
+
+// Runs against the database at DATABASE_URL
+func TestQuotesFind(t *testing.T) {
+	guild := connectDatabase(t)
+	otherGuild := guild + "1"
+	author := &dg.Member{User: &dg.User{ID: "400000000000000001", Username: "author"}}
+	found := addQuote(t, guild, author.User.ID, "the quick brown fox")
+	addQuote(t, guild, author.User.ID, "the lazy dog")
+	addQuote(t, otherGuild, author.User.ID, "a fox of another guild")
+
+	session := replay.NewSession()
+	session.AddGuild(&dg.Guild{ID: guild, Name: "Replay", Members: []*dg.Member{author}})
+	config.FeaturesEnabled.Seed(guild, []string{"quotes"})
+	database.CommandRuleCache.Add(guild, nil)
+
+	session.Interact(replay.CommandInteraction(guild, "300000000000000001", author, "quotes",
+		replay.Subcommand("find", replay.Option("text_search", "fox"))))
+
+	response := interactionResponse(t, session)
+	if response.Data == nil || len(response.Data.Embeds) != 1 {
+		t.Fatalf("response %+v is not a page of quotes", response.Data)
+	}
+	fields := response.Data.Embeds[0].Fields
+	if len(fields) != 1 {
+		t.Fatalf("found %d quotes, want 1: %+v", len(fields), fields)
+	}
+	if want := "#" + strconv.FormatUint(uint64(found.ID), 10); !strings.Contains(fields[0].Name, want) || fields[0].Value != found.Content {
+		t.Errorf("found %q: %q, want quote %s", fields[0].Name, fields[0].Value, want)
+	}
+}
+
+// Runs against the database at DATABASE_URL
+func TestQuotesOfOtherGuilds(t *testing.T) {
+	guild := connectDatabase(t)
+	author := &dg.Member{User: &dg.User{ID: "400000000000000001", Username: "author"}}
+	foreign := addQuote(t, guild+"1", author.User.ID, "a secret of another guild")
+	id := replay.TypedOption("id", dg.ApplicationCommandOptionInteger, int(foreign.ID))
+
+	for _, subcommand := range []string{"get", "delete"} {
+		t.Run(subcommand, func(t *testing.T) {
+			session := replay.NewSession()
+			session.AddGuild(&dg.Guild{ID: guild, Name: "Replay", Members: []*dg.Member{author}})
+			config.FeaturesEnabled.Seed(guild, []string{"quotes"})
+			database.CommandRuleCache.Add(guild, nil)
+
+			session.Interact(replay.CommandInteraction(guild, "300000000000000001", author, "quotes",
+				replay.Subcommand(subcommand, id)))
+
+			for _, call := range session.API.Calls() {
+				if strings.Contains(string(call.Body), foreign.Content) {
+					t.Errorf("%s shows the quote of another guild", call)
+				}
+			}
+			if err := database.Database.Take(&database.Quote{ID: foreign.ID}).Error; err != nil {
+				t.Errorf("quote of another guild is gone: %v", err)
+			}
+		})
+	}
+}
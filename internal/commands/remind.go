@@ -2,9 +2,9 @@ package commands
 
 import (
 	"encoding/json"
-	"errors"
 	"fmt"
 	"snoozybot/internal/database"
+	"snoozybot/internal/features"
 	"snoozybot/internal/i18n"
 	"time"
 
@@ -19,6 +19,8 @@ var reminder = BotCommand{
 	ApplicationCommand: dg.ApplicationCommand{
 		Name: "reminder",
 	},
+	Feature:  features.Reminders,
+	Contexts: PersonalContexts,
 	Subcommands: []*BotCommand{
 		&reminderSet,
 		&reminderCancel,
@@ -37,7 +39,7 @@ var reminderSet = BotCommand{
 		},
 	},
 	CommandHandler: func(cd *CommandData) error {
-		user := database.User{UserID: cd.Member.User.ID}
+		user := database.User{UserID: cd.Invoker().ID}
 		res := database.Database.Select("timezone").Take(&user)
 		if user.Timezone == nil {
 			return cd.Respond(Response{Key: "reminder/set.timezone"})
@@ -57,19 +59,24 @@ var reminderSet = BotCommand{
 		if parsedTime.Time.Before(time.Now()) {
 			return cd.Respond(Response{Key: "reminder/set.past"})
 		}
-		payload, err := json.Marshal(database.ScheduledTaskReminderPayload{
+		payload := database.ScheduledTaskReminderPayload{
 			ChannelID: cd.ChannelID,
 			Reason:    message,
-		})
+		}
+		if cd.GuildID == "" {
+			payload.ApplicationID = cd.AppID
+			payload.Locale = string(cd.Locale)
+		}
+		encoded, err := json.Marshal(payload)
 		if err != nil {
 			return err
 		}
 		task := database.ScheduledTask{
 			GuildID:      cd.GuildID,
 			TaskType:     database.TaskTypeReminder,
-			UserID:       cd.Member.User.ID,
+			UserID:       cd.Invoker().ID,
 			ProcessAfter: parsedTime.Time,
-			Payload:      payload,
+			Payload:      encoded,
 		}
 		if res := database.Database.Create(&task); res.Error != nil {
 			return res.Error
@@ -89,59 +96,74 @@ var reminderCancel = BotCommand{
 			{Name: "id", Type: dg.ApplicationCommandOptionInteger, Required: true},
 		},
 	},
+	Autocomplete: map[string]Autocomplete{"id": autocompleteReminders},
 	CommandHandler: func(cd *CommandData) error {
 		id := cd.Option("id").UintValue()
-		task := database.ScheduledTask{ID: uint(id), GuildID: cd.GuildID, TaskType: database.TaskTypeReminder}
-		if res := database.Database.Delete(&task); res.Error != nil {
-			if errors.Is(res.Error, gorm.ErrRecordNotFound) {
-				return cd.Respond(Response{Key: "reminder/cancel.missing"})
-			}
+		res := reminderQuery(cd).Delete(&database.ScheduledTask{ID: uint(id)})
+		if res.Error != nil {
 			return res.Error
+		} else if res.RowsAffected == 0 {
+			return cd.Respond(Response{Key: "reminder/cancel.missing"})
 		}
-		return cd.Respond(Response{Key: "reminder/clear.success"})
+		return cd.Respond(Response{Key: "reminder/cancel.success", Vars: &i18n.Vars{"id": id}})
 	},
 }
 
 var reminderList = BotCommand{
 	ApplicationCommand: dg.ApplicationCommand{Name: "list"},
 	CommandHandler: func(cd *CommandData) error {
+		return reminderListPages.Respond(cd)
+	},
+}
+
+// Reminders set in the channel the list was requested in, soonest first
+var reminderListPages = newPaginator(Paginator{
+	Name:     "reminders",
+	PageSize: 10,
+	EmptyKey: "reminder/list.empty",
+	Count: func(cd *CommandData, args []string) (int64, error) {
+		var count int64
+		return count, reminderListQuery(cd).Model(&database.ScheduledTask{}).Count(&count).Error
+	},
+	Page: func(cd *CommandData, args []string, offset int, limit int) ([]*dg.MessageEmbedField, error) {
 		var tasks []*database.ScheduledTask
-		if res := database.Database.Where(
-			&database.ScheduledTask{GuildID: cd.GuildID, TaskType: database.TaskTypeReminder},
-			datatypes.JSONQuery("payload").Equals(cd.ChannelID, "ChannelID"),
-		).Order("process_after").Limit(11).Find(&tasks); res.Error != nil {
-			return res.Error
-		} else if res.RowsAffected == 0 {
-			return cd.Respond(Response{Key: "reminder/list.empty"})
-		}
-		embed := &dg.MessageEmbed{
-			Fields: lo.Map(lo.Slice(tasks, 0, 10), func(t *database.ScheduledTask, _ int) *dg.MessageEmbedField {
-				var payload database.ScheduledTaskReminderPayload
-				var userName string
-				if err := json.Unmarshal(t.Payload, &payload); err != nil {
-					cd.Log.Error().Any("payload", t.Payload).Msg("Failed to unmarshal JSON for scheduled task when loading reminder. Skipping.")
-				}
-				if user, err := cd.GuildMember(cd.GuildID, t.UserID); err == nil {
-					userName = user.DisplayName()
-				} else {
-					cd.Log.Warn().Str("guild", cd.GuildID).Str("user", t.UserID).Err(err).Msg("Failed to get user for reminder")
-					userName = "Unknown User"
-				}
-				return &dg.MessageEmbedField{
-					Name:  fmt.Sprintf("[#%d] %s", t.ID, userName),
-					Value: payload.Reason,
-				}
-			}),
-		}
-		if len(tasks) > 10 {
-			embed.Footer = &dg.MessageEmbedFooter{Text: i18n.Get(*cd.GuildLocale, "reminders/list.hasMore")}
+		if res := reminderListQuery(cd).Order("process_after, id").Offset(offset).Limit(limit).Find(&tasks); res.Error != nil {
+			return nil, res.Error
 		}
-		return cd.InteractionRespond(cd.Interaction, &dg.InteractionResponse{
-			Type: dg.InteractionResponseChannelMessageWithSource,
-			Data: &dg.InteractionResponseData{
-				Embeds: []*dg.MessageEmbed{embed},
-				Flags:  dg.MessageFlagsEphemeral,
-			},
-		})
+		return lo.Map(tasks, func(t *database.ScheduledTask, _ int) *dg.MessageEmbedField {
+			var payload database.ScheduledTaskReminderPayload
+			var userName string
+			if err := json.Unmarshal(t.Payload, &payload); err != nil {
+				cd.Log.Error().Any("payload", t.Payload).Msg("Failed to unmarshal JSON for scheduled task when loading reminder. Skipping.")
+			}
+			if user, err := cd.GuildMember(cd.GuildID, t.UserID); err == nil {
+				userName = user.DisplayName()
+			} else {
+				cd.Log.Warn().Str("guild", cd.GuildID).Str("user", t.UserID).Err(err).Msg("Failed to get user for reminder")
+				userName = "Unknown User"
+			}
+			return &dg.MessageEmbedField{
+				Name:  fmt.Sprintf("[#%d] %s", t.ID, userName),
+				Value: payload.Reason,
+			}
+		}), nil
 	},
+})
+
+func reminderListQuery(cd *CommandData) *gorm.DB {
+	return database.Database.Where(
+		&database.ScheduledTask{GuildID: cd.GuildID, TaskType: database.TaskTypeReminder},
+		// the JSON key of ScheduledTaskReminderPayload.ChannelID
+		datatypes.JSONQuery("payload").Equals(cd.ChannelID, "channel"),
+	)
+}
+
+// Reminders that the user may cancel: those of the guild, or outside of guilds only their own
+func reminderQuery(cd *CommandData) *gorm.DB {
+	// a struct condition would skip the empty guild ID of reminders set outside of guilds
+	query := database.Database.Where("guild_id = ? AND task_type = ?", cd.GuildID, database.TaskTypeReminder)
+	if cd.GuildID == "" {
+		query = query.Where("user_id = ?", cd.Invoker().ID)
+	}
+	return query
 }
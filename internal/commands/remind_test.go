@@ -0,0 +1,92 @@
+package commands_test
+
+import (
+	"encoding/json"
+	"os"
+	"snoozybot/internal/config"
+	"snoozybot/internal/database"
+	"snoozybot/internal/replay"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	dg "github.com/bwmarrin/discordgo"
+)
+
+// Connects to the database at DATABASE_URL, or skips the test without one.
+// Returns a guild of the test's own, so rows of other runs don't show up in its queries.
+func connectDatabase(t *testing.T) string {
+	t.Helper()
+	if _, ok := os.LookupEnv("DATABASE_URL"); !ok {
+		t.Skip("DATABASE_URL is not set")
+	}
+	if err := database.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	return strconv.FormatInt(time.Now().UnixNano(), 10)
+}
+
+// Decodes the only interaction response the session sent
+func interactionResponse(t *testing.T, session *replay.Session) *dg.InteractionResponse {
+	t.Helper()
+	calls := session.API.Calls("POST /interactions/")
+	if len(calls) != 1 {
+		t.Fatalf("sent %d responses, want 1: %v", len(calls), session.API.Calls())
+	}
+	var response dg.InteractionResponse
+	if err := calls[0].Decode(&response); err != nil {
+		t.Fatal(err)
+	}
+	return &response
+}
+
+// Runs against the database at DATABASE_URL; the reminders are deleted afterwards
+func TestReminderList(t *testing.T) {
+	guild := connectDatabase(t)
+	otherGuild := guild + "1"
+	const channelID, otherChannelID = "300000000000000001", "300000000000000002"
+	author := &dg.Member{User: &dg.User{ID: "400000000000000001", Username: "author"}}
+	t.Cleanup(func() {
+		database.Database.Where("guild_id IN ?", []string{guild, otherGuild}).Delete(&database.ScheduledTask{})
+	})
+	remind := func(guildID, channelID, reason string) *database.ScheduledTask {
+		payload, err := json.Marshal(database.ScheduledTaskReminderPayload{ChannelID: channelID, Reason: reason})
+		if err != nil {
+			t.Fatal(err)
+		}
+		task := &database.ScheduledTask{
+			GuildID:      guildID,
+			UserID:       author.User.ID,
+			TaskType:     database.TaskTypeReminder,
+			ProcessAfter: time.Now().Add(time.Hour),
+			Payload:      payload,
+		}
+		if err := database.Database.Create(task).Error; err != nil {
+			t.Fatal(err)
+		}
+		return task
+	}
+	listed := remind(guild, channelID, "water the plants")
+	remind(guild, otherChannelID, "feed the cat")
+	remind(otherGuild, channelID, "walk the dog")
+
+	session := replay.NewSession()
+	session.AddGuild(&dg.Guild{ID: guild, Name: "Replay", Members: []*dg.Member{author}})
+	config.FeaturesEnabled.Seed(guild, []string{"reminders"})
+	database.CommandRuleCache.Add(guild, nil)
+
+	session.Interact(replay.CommandInteraction(guild, channelID, author, "reminder", replay.Subcommand("list")))
+
+	response := interactionResponse(t, session)
+	if response.Data == nil || len(response.Data.Embeds) != 1 {
+		t.Fatalf("response %+v is not a page of reminders", response.Data)
+	}
+	fields := response.Data.Embeds[0].Fields
+	if len(fields) != 1 {
+		t.Fatalf("listed %d reminders, want 1: %+v", len(fields), fields)
+	}
+	if want := "#" + strconv.FormatUint(uint64(listed.ID), 10); !strings.Contains(fields[0].Name, want) || fields[0].Value != "water the plants" {
+		t.Errorf("listed %q: %q, want reminder %s", fields[0].Name, fields[0].Value, want)
+	}
+}
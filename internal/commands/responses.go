@@ -0,0 +1,193 @@
+package commands
+
+import (
+	"io"
+	"sync"
+	"time"
+
+	dg "github.com/bwmarrin/discordgo"
+	"github.com/samber/lo"
+)
+
+// Discord fails interactions that are not acknowledged within 3 seconds. Handlers still running after this long are
+// deferred automatically, which shows a "thinking" message until they respond.
+const AutoDeferAfter = 2 * time.Second
+
+type responseStatus int
+
+const (
+	responsePending  responseStatus = iota // nothing was sent yet
+	responseDeferred                       // acknowledged, but the original response is still empty
+	responseSent                           // the original response was written; further messages are followups
+)
+
+// Tracks how the interaction was acknowledged, so responses take the right path. Guarded by a mutex because the
+// automatic deferral runs on a timer.
+type responseState struct {
+	mu        sync.Mutex
+	status    responseStatus
+	ephemeral bool // the deferred message is only visible to the user
+	update    bool // a component interaction was deferred as an update of its message
+}
+
+// Acknowledges the interaction, showing a "thinking" message until the handler responds. Component interactions are
+// deferred as an update of their message instead. The visibility of the thinking message carries over to the
+// response. Does nothing if the interaction was already acknowledged.
+func (cd *CommandData) Defer(public bool) error {
+	cd.response.mu.Lock()
+	defer cd.response.mu.Unlock()
+	if cd.response.status != responsePending {
+		return nil
+	}
+	// modals submitted from a component are answered like the component
+	if cd.Type == dg.InteractionMessageComponent || cd.Type == dg.InteractionModalSubmit && cd.Message != nil {
+		return cd.deferUpdate()
+	}
+	response := &dg.InteractionResponse{Type: dg.InteractionResponseDeferredChannelMessageWithSource}
+	if !public {
+		response.Data = &dg.InteractionResponseData{Flags: dg.MessageFlagsEphemeral}
+	}
+	if err := cd.InteractionRespond(cd.Interaction, response); err != nil {
+		return err
+	}
+	cd.response.status = responseDeferred
+	cd.response.ephemeral = !public
+	return nil
+}
+
+// Defers the interaction if the handler has not responded after the given duration. The returned function stops the
+// timer and must be called once the handler returns.
+func (cd *CommandData) AutoDefer(after time.Duration, public bool) (stop func()) {
+	timer := time.AfterFunc(after, func() {
+		if err := cd.Defer(public); err != nil {
+			cd.Log.Warn().Err(err).Msg("Failed to defer slow interaction")
+		} else {
+			cd.Log.Debug().Dur("after", after).Msg("Deferred slow interaction")
+		}
+	})
+	return func() { timer.Stop() }
+}
+
+// Replaces the original response, e.g. to show the progress of a long running command. If nothing was sent yet, this
+// responds instead and the returned message is nil.
+func (cd *CommandData) EditResponse(r Response) (*dg.Message, error) {
+	cd.response.mu.Lock()
+	defer cd.response.mu.Unlock()
+	if cd.response.status == responsePending {
+		setVisibility(&r)
+		cd.localize(&r)
+		return nil, cd.respond(&r)
+	}
+	cd.localize(&r)
+	message, err := cd.editOriginal(&r.InteractionResponseData)
+	if err == nil {
+		cd.response.status = responseSent
+	}
+	return message, err
+}
+
+// Sends another message after the response. If nothing was sent yet, this responds instead and the returned message
+// is nil.
+func (cd *CommandData) Followup(r Response) (*dg.Message, error) {
+	setVisibility(&r)
+	cd.localize(&r)
+	cd.response.mu.Lock()
+	defer cd.response.mu.Unlock()
+	if cd.response.status == responsePending {
+		return nil, cd.respond(&r)
+	}
+	return cd.followup(&r.InteractionResponseData)
+}
+
+// Answers an autocomplete interaction with the given suggestions. Discord shows at most 25.
+func (cd *CommandData) RespondChoices(choices []*dg.ApplicationCommandOptionChoice) error {
+	return cd.InteractionRespond(cd.Interaction, &dg.InteractionResponse{
+		Type: dg.InteractionApplicationCommandAutocompleteResult,
+		Data: &dg.InteractionResponseData{Choices: lo.Slice(choices, 0, 25)},
+	})
+}
+
+// Attaches a file to the response
+func (r Response) WithFile(name string, contentType string, reader io.Reader) Response {
+	r.Files = append(r.Files, &dg.File{Name: name, ContentType: contentType, Reader: reader})
+	return r
+}
+
+func setVisibility(r *Response) {
+	if r.Public {
+		r.Flags &= ^dg.MessageFlagsEphemeral
+	} else {
+		r.Flags |= dg.MessageFlagsEphemeral
+	}
+}
+
+// Sends the response through whichever path is still open. Must be called with the response lock held.
+func (cd *CommandData) respond(r *Response) error {
+	switch cd.response.status {
+	case responsePending:
+		err := cd.InteractionRespond(cd.Interaction, &dg.InteractionResponse{
+			Type: dg.InteractionResponseChannelMessageWithSource,
+			Data: &r.InteractionResponseData,
+		})
+		if err == nil {
+			cd.response.status = responseSent
+		}
+		return err
+	case responseDeferred:
+		ephemeral := r.Flags&dg.MessageFlagsEphemeral != 0
+		if !cd.response.update {
+			if cd.response.ephemeral == ephemeral {
+				_, err := cd.editOriginal(&r.InteractionResponseData)
+				if err == nil {
+					cd.response.status = responseSent
+				}
+				return err
+			}
+			// the thinking message cannot change visibility, so it is replaced by a followup
+			if err := cd.InteractionResponseDelete(cd.Interaction); err != nil {
+				return err
+			}
+		}
+		cd.response.status = responseSent
+	}
+	_, err := cd.followup(&r.InteractionResponseData)
+	return err
+}
+
+// Edits the original response, leaving out empty fields like an update message response does
+func (cd *CommandData) editOriginal(data *dg.InteractionResponseData) (*dg.Message, error) {
+	edit := &dg.WebhookEdit{Files: data.Files, AllowedMentions: data.AllowedMentions}
+	if data.Content != "" {
+		edit.Content = &data.Content
+	}
+	if data.Components != nil {
+		edit.Components = &data.Components
+	}
+	if data.Embeds != nil {
+		edit.Embeds = &data.Embeds
+	}
+	return cd.InteractionResponseEdit(cd.Interaction, edit)
+}
+
+func (cd *CommandData) followup(data *dg.InteractionResponseData) (*dg.Message, error) {
+	return cd.FollowupMessageCreate(cd.Interaction, true, &dg.WebhookParams{
+		Content:         data.Content,
+		Components:      data.Components,
+		Embeds:          data.Embeds,
+		Files:           data.Files,
+		AllowedMentions: data.AllowedMentions,
+		Flags:           data.Flags,
+	})
+}
+
+// Must be called with the response lock held
+func (cd *CommandData) deferUpdate() error {
+	err := cd.InteractionRespond(cd.Interaction, &dg.InteractionResponse{
+		Type: dg.InteractionResponseDeferredMessageUpdate,
+	})
+	if err == nil {
+		cd.response.status = responseDeferred
+		cd.response.update = true
+	}
+	return err
+}
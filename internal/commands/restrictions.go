@@ -0,0 +1,99 @@
+package commands
+
+import (
+	"slices"
+	"snoozybot/internal/database"
+	"strings"
+
+	dg "github.com/bwmarrin/discordgo"
+)
+
+// Enforces the guild's command rules for the command at path. Added automatically to every command.
+//
+// Rules of a command come from the command itself or, if it has none, its closest parent with rules. Channels and
+// roles are evaluated separately: deny rules block their channels or roles, and allow rules block every other channel,
+// or members without any of the allowed roles. Administrators are never blocked, so /admin cannot be locked away.
+func restrict(path string) Middleware {
+	return Check(func(cd *CommandData) *Response {
+		if !rulesAllowCommand(cd, path) {
+			cd.Log.Info().Msg("Command blocked by command rules")
+			return &Response{Key: "base.restricted"}
+		}
+		return nil
+	})
+}
+
+// Whether the guild's command rules let the member use the command at path in the channel of the interaction
+func rulesAllowCommand(cd *CommandData, path string) bool {
+	if cd.GuildID == "" || cd.Member == nil || cd.Member.Permissions&dg.PermissionAdministrator != 0 {
+		return true
+	}
+	rules, err := database.GetCommandRules(cd.GuildID)
+	if err != nil {
+		cd.Log.Error().Err(err).Msg("Failed to load command rules. Allowing the command.")
+		return true
+	}
+	if len(rules) == 0 {
+		return true
+	}
+	channels := []string{cd.ChannelID}
+	if channel, err := cd.State.Channel(cd.ChannelID); err == nil && channel.IsThread() {
+		channels = append(channels, channel.ParentID)
+	}
+	// the @everyone role has the ID of the guild
+	roles := append([]string{cd.GuildID}, cd.Member.Roles...)
+	return rulesAllow(rules, path, database.CommandRuleChannel, channels) && rulesAllow(rules, path, database.CommandRuleRole, roles)
+}
+
+func rulesAllow(rules []database.CommandRule, path string, target database.CommandRuleTarget, ids []string) bool {
+	applicable := closestRules(rules, path, target)
+	hasAllow, allowed := false, false
+	for _, rule := range applicable {
+		matches := slices.Contains(ids, rule.TargetID)
+		if matches && !rule.Allow {
+			return false
+		}
+		hasAllow = hasAllow || rule.Allow
+		allowed = allowed || matches && rule.Allow
+	}
+	return !hasAllow || allowed
+}
+
+// Returns the rules of the given target type for the command, or for its closest parent that has any
+func closestRules(rules []database.CommandRule, path string, target database.CommandRuleTarget) []database.CommandRule {
+	for {
+		var matching []database.CommandRule
+		for _, rule := range rules {
+			if rule.CommandPath == path && rule.TargetType == target {
+				matching = append(matching, rule)
+			}
+		}
+		if len(matching) > 0 {
+			return matching
+		}
+		i := strings.LastIndex(path, "/")
+		if i < 0 {
+			return nil
+		}
+		path = path[:i]
+	}
+}
+
+// Same as Commands. Assigned in init, since the commands that look up other commands are part of Commands themselves.
+var commandTree []*BotCommand
+
+func init() {
+	commandTree = Commands
+}
+
+// Returns the command at a full path like "quotes/find", or nil if there is none. Only works after the commands were
+// built.
+func findCommand(path string) *BotCommand {
+	root, _, _ := strings.Cut(path, "/")
+	for _, cmd := range commandTree {
+		if cmd.Name == root {
+			return cmd.Lookup(path)
+		}
+	}
+	return nil
+}
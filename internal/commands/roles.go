@@ -6,6 +6,7 @@ import (
 	"slices"
 	"snoozybot/internal/config"
 	"snoozybot/internal/database"
+	"snoozybot/internal/features"
 	"snoozybot/internal/i18n"
 	"time"
 
@@ -20,14 +21,13 @@ var assignTempRole = BotCommand{
 		Type:                     dg.UserApplicationCommand,
 		DefaultMemberPermissions: &CommandPermissionModeratorOnly,
 	},
+	Feature:     features.RolesTemp,
+	Middlewares: []Middleware{GuildOnly, RequirePermissions(dg.PermissionManageMessages), AuditLog},
 	CommandHandler: func(cd *CommandData) error {
 		target := cd.ApplicationCommandData().TargetID
-		if target == cd.Member.User.ID {
+		if target == cd.Invoker().ID {
 			return cd.Respond(Response{Key: "roles.self"})
 		}
-		if cd.Member.Permissions&dg.PermissionManageMessages == 0 {
-			return cd.Respond(Response{Key: "roles.notMod"})
-		}
 		tempRoleID, err := config.RolesTempRoleID.Get(cd.GuildID).Value()
 		if err != nil || tempRoleID == "" {
 			return cd.Respond(Response{Key: "roles.notAvailable"})
@@ -79,12 +79,11 @@ var assignRegularsRole = BotCommand{
 		Type:                     dg.UserApplicationCommand,
 		DefaultMemberPermissions: &CommandPermissionModeratorOnly,
 	},
+	Feature:     features.RolesRegulars,
+	Middlewares: []Middleware{GuildOnly, RequirePermissions(dg.PermissionManageMessages), AuditLog},
 	CommandHandler: func(cd *CommandData) error {
-		if cd.Member.Permissions&dg.PermissionManageMessages == 0 {
-			return cd.Respond(Response{Key: "roles.notMod"})
-		}
 		target := cd.ApplicationCommandData().TargetID
-		if target == cd.Member.User.ID {
+		if target == cd.Invoker().ID {
 			return cd.Respond(Response{Key: "roles.self"})
 		}
 		targetMember, err := cd.Session.GuildMember(cd.GuildID, target)
@@ -141,6 +140,7 @@ func TryAssignRegularsRole(guildID string, member *dg.Member, logger *zerolog.Lo
 	}
 
 	// Finally delete the metric row - no longer needed
-	database.Database.Delete(&metric)
+	// by guild and user, since members can qualify without a row
+	database.Database.Where(&database.MessageMetric{GuildID: guildID, UserID: member.User.ID}).Delete(&database.MessageMetric{})
 	return Response{Key: "roles.regulars.success", Vars: &i18n.Vars{"target": member.User.Mention()}}
 }
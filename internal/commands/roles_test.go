@@ -0,0 +1,74 @@
+package commands_test
+
+import (
+	"encoding/json"
+	"snoozybot/internal/commands"
+	"snoozybot/internal/config"
+	"snoozybot/internal/database"
+	"snoozybot/internal/replay"
+	"strconv"
+	"testing"
+	"time"
+
+	dg "github.com/bwmarrin/discordgo"
+	"github.com/rs/zerolog"
+	"gorm.io/gorm"
+)
+
+func TestTryAssignRegularsRole(t *testing.T) {
+	// the message metric lookup is built but not run, so members have no messages on record
+	db := database.Database
+	database.Database = db.Session(&gorm.Session{DryRun: true, SkipDefaultTransaction: true})
+	t.Cleanup(func() { database.Database = db })
+	const roleID = "500000000000000001"
+	logger := zerolog.Nop()
+
+	tests := []struct {
+		name      string
+		role      bool
+		messages  uint
+		joined    uint
+		active    uint
+		roles     []string
+		daysAgo   int
+		want      string
+		assigning bool
+	}{
+		{name: "no role", joined: 1, daysAgo: 10, want: "roles.notAvailable"},
+		{name: "no conditions", role: true, daysAgo: 10, want: "roles.notAvailable"},
+		{name: "already a regular", role: true, joined: 1, roles: []string{roleID}, daysAgo: 10, want: "roles.alreadyHasRole"},
+		{name: "joined recently", role: true, joined: 7, daysAgo: 3, want: "roles.regulars.joinTimeNotMet"},
+		{name: "too few messages", role: true, messages: 100, daysAgo: 10, want: "roles.regulars.messageCountNotMet"},
+		{name: "too few active days", role: true, active: 5, daysAgo: 10, want: "roles.regulars.distinctDaysNotMet"},
+		{name: "regular", role: true, joined: 7, daysAgo: 10, want: "roles.regulars.success", assigning: true},
+	}
+	for i, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// a guild per test, since unset keys can't be seeded
+			guildID := "20000000000000010" + strconv.Itoa(i)
+			if test.role {
+				config.RolesRegularsRoleID.Seed(guildID, json.Number(roleID))
+			}
+			config.RolesRegularsMinMessages.Seed(guildID, test.messages)
+			config.RolesRegularsMinDaysJoined.Seed(guildID, test.joined)
+			config.RolesRegularsMinDaysActive.Seed(guildID, test.active)
+			member := &dg.Member{
+				GuildID:  guildID,
+				User:     &dg.User{ID: "400000000000000001", Username: "member"},
+				Roles:    test.roles,
+				JoinedAt: time.Now().AddDate(0, 0, -test.daysAgo),
+			}
+			session := replay.NewSession()
+
+			response := commands.TryAssignRegularsRole(guildID, member, &logger, session.Session)
+
+			if response.Key != test.want {
+				t.Errorf("responded %q, want %q", response.Key, test.want)
+			}
+			calls := session.API.Calls("PUT /guilds/" + guildID + "/members/" + member.User.ID + "/roles/" + roleID)
+			if assigned := len(calls) == 1; assigned != test.assigning {
+				t.Errorf("assigned the role: %v, want %v (calls: %v)", assigned, test.assigning, session.API.Calls())
+			}
+		})
+	}
+}
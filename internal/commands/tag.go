@@ -0,0 +1,325 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"snoozybot/internal/database"
+	"snoozybot/internal/features"
+	"snoozybot/internal/i18n"
+	"strconv"
+	"strings"
+	"text/template"
+	"text/template/parse"
+	"time"
+	"unicode/utf8"
+
+	dg "github.com/bwmarrin/discordgo"
+	"github.com/samber/lo"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Tag names are typed by users, so they are kept short and simple
+var tagNamePattern = regexp.MustCompile(`^[\p{L}\p{N}_-]{1,32}$`)
+
+// Discord rejects messages longer than this, so longer tags aren't sent
+const maxTagLength = 2000
+
+const tagRenderTimeout = time.Second
+
+var errTagTooLong = errors.New("tag is too long")
+
+type tagOptions struct {
+	Name string     `option:"name,required"`
+	User *dg.Member `option:"user"`
+}
+
+var tag = BotCommand{
+	ApplicationCommand: dg.ApplicationCommand{Name: "tag"},
+	Feature:            features.Tags,
+	DeferPublic:        true,
+	Autocomplete:       map[string]Autocomplete{"name": autocompleteTags},
+	Bind: Bind(func(cd *CommandData, opts *tagOptions) error {
+		t := database.Tag{GuildID: cd.GuildID, Name: normalizeTagName(opts.Name)}
+		if res := database.Database.Take(&t); res.Error != nil {
+			if errors.Is(res.Error, gorm.ErrRecordNotFound) {
+				return cd.Respond(Response{Key: "tag.missing", Vars: &i18n.Vars{"name": opts.Name}})
+			}
+			return res.Error
+		}
+		templ, err := parseTag(t.Name, t.Content)
+		if err != nil {
+			// tags are validated when saved, so this only happens if the template was changed in the database
+			return err
+		}
+		content, err := renderTag(templ, tagVars(cd, lo.CoalesceOrEmpty(opts.User, cd.Member)))
+		if errors.Is(err, errTagTooLong) {
+			return cd.Respond(Response{Key: "tag.tooLong", Vars: &i18n.Vars{"name": t.Name, "max": maxTagLength}})
+		} else if err != nil {
+			return cd.Respond(Response{Key: "tag.renderFailed", Vars: &i18n.Vars{"name": t.Name, "error": err.Error()}})
+		}
+		r := Response{Public: true, InteractionResponseData: dg.InteractionResponseData{
+			AllowedMentions: tagAllowedMentions(t.Mentions),
+		}}
+		if t.Embed {
+			r.Embeds = []*dg.MessageEmbed{{Title: t.EmbedTitle, Description: content, Color: t.EmbedColor}}
+		} else {
+			r.Content = content
+		}
+		return cd.Respond(r)
+	}),
+}
+
+var tags = BotCommand{
+	ApplicationCommand: dg.ApplicationCommand{Name: "tags", DefaultMemberPermissions: &CommandPermissionModeratorOnly},
+	Feature:            features.Tags,
+	Middlewares:        []Middleware{GuildOnly, RequirePermissions(dg.PermissionManageMessages), AuditLog},
+	Subcommands: []*BotCommand{
+		&tagsAdd,
+		&tagsEdit,
+		&tagsDelete,
+		&tagsList,
+	},
+}
+
+type tagsAddOptions struct {
+	Name       string `option:"name,required,maxlen=32"`
+	Content    string `option:"content,required,maxlen=2000"`
+	Embed      bool   `option:"embed"`
+	EmbedTitle string `option:"embed_title,maxlen=256"`
+	EmbedColor string `option:"embed_color,maxlen=7"`
+	Mentions   string `option:"mentions,choices=none|users|roles|everyone"`
+}
+
+var tagsAdd = BotCommand{
+	ApplicationCommand: dg.ApplicationCommand{Name: "add"},
+	Bind: Bind(func(cd *CommandData, opts *tagsAddOptions) error {
+		t := database.Tag{
+			GuildID:    cd.GuildID,
+			Name:       normalizeTagName(opts.Name),
+			Content:    opts.Content,
+			Embed:      opts.Embed,
+			EmbedTitle: opts.EmbedTitle,
+			Mentions:   database.TagMentions(lo.CoalesceOrEmpty(opts.Mentions, string(database.TagMentionsNone))),
+			CreatedBy:  cd.Invoker().ID,
+		}
+		if r := validateTag(&t, opts.EmbedColor); r != nil {
+			return cd.Respond(*r)
+		}
+		res := database.Database.Clauses(clause.OnConflict{DoNothing: true}).Create(&t)
+		if res.Error != nil {
+			return res.Error
+		} else if res.RowsAffected == 0 {
+			return cd.Respond(Response{Key: "tags.add.exists", Vars: &i18n.Vars{"name": t.Name}})
+		}
+		cd.Log.Info().Str("tag", t.Name).Msg("Added tag")
+		return cd.Respond(Response{Key: "tags.add.success", Vars: &i18n.Vars{"name": t.Name}})
+	}),
+}
+
+type tagsEditOptions struct {
+	Name       string  `option:"name,required"`
+	Content    *string `option:"content,maxlen=2000"`
+	Embed      *bool   `option:"embed"`
+	EmbedTitle *string `option:"embed_title,maxlen=256"`
+	EmbedColor string  `option:"embed_color,maxlen=7"`
+	Mentions   *string `option:"mentions,choices=none|users|roles|everyone"`
+}
+
+// Only the given options are changed
+var tagsEdit = BotCommand{
+	ApplicationCommand: dg.ApplicationCommand{Name: "edit"},
+	Autocomplete:       map[string]Autocomplete{"name": autocompleteTags},
+	Bind: Bind(func(cd *CommandData, opts *tagsEditOptions) error {
+		t := database.Tag{GuildID: cd.GuildID, Name: normalizeTagName(opts.Name)}
+		if res := database.Database.Take(&t); res.Error != nil {
+			if errors.Is(res.Error, gorm.ErrRecordNotFound) {
+				return cd.Respond(Response{Key: "tag.missing", Vars: &i18n.Vars{"name": opts.Name}})
+			}
+			return res.Error
+		}
+		t.Content = lo.FromPtrOr(opts.Content, t.Content)
+		t.Embed = lo.FromPtrOr(opts.Embed, t.Embed)
+		t.EmbedTitle = lo.FromPtrOr(opts.EmbedTitle, t.EmbedTitle)
+		t.Mentions = database.TagMentions(lo.FromPtrOr(opts.Mentions, string(t.Mentions)))
+		if r := validateTag(&t, opts.EmbedColor); r != nil {
+			return cd.Respond(*r)
+		}
+		// unlike Updates, Save also writes zero values, e.g. when turning the embed off
+		if err := database.Database.Save(&t).Error; err != nil {
+			return err
+		}
+		cd.Log.Info().Str("tag", t.Name).Msg("Edited tag")
+		return cd.Respond(Response{Key: "tags.edit.success", Vars: &i18n.Vars{"name": t.Name}})
+	}),
+}
+
+type tagsDeleteOptions struct {
+	Name string `option:"name,required"`
+}
+
+var tagsDelete = BotCommand{
+	ApplicationCommand: dg.ApplicationCommand{Name: "delete"},
+	Autocomplete:       map[string]Autocomplete{"name": autocompleteTags},
+	Bind: Bind(func(cd *CommandData, opts *tagsDeleteOptions) error {
+		name := normalizeTagName(opts.Name)
+		res := database.Database.Delete(&database.Tag{GuildID: cd.GuildID, Name: name})
+		if res.Error != nil {
+			return res.Error
+		} else if res.RowsAffected == 0 {
+			return cd.Respond(Response{Key: "tag.missing", Vars: &i18n.Vars{"name": opts.Name}})
+		}
+		cd.Log.Info().Str("tag", name).Msg("Deleted tag")
+		return cd.Respond(Response{Key: "tags.delete.success", Vars: &i18n.Vars{"name": name}})
+	}),
+}
+
+var tagsList = BotCommand{
+	ApplicationCommand: dg.ApplicationCommand{Name: "list"},
+	CommandHandler: func(cd *CommandData) error {
+		return tagPages.Respond(cd)
+	},
+}
+
+var tagPages = newPaginator(Paginator{
+	Name:     "tags",
+	PageSize: 10,
+	EmptyKey: "tags.list.empty",
+	Count: func(cd *CommandData, args []string) (int64, error) {
+		var count int64
+		return count, database.Database.Model(&database.Tag{}).Where(&database.Tag{GuildID: cd.GuildID}).Count(&count).Error
+	},
+	Page: func(cd *CommandData, args []string, offset int, limit int) ([]*dg.MessageEmbedField, error) {
+		var found []*database.Tag
+		if res := database.Database.Where(&database.Tag{GuildID: cd.GuildID}).Order("name").Offset(offset).Limit(limit).Find(&found); res.Error != nil {
+			return nil, res.Error
+		}
+		return lo.Map(found, func(t *database.Tag, _ int) *dg.MessageEmbedField {
+			return &dg.MessageEmbedField{Name: t.Name, Value: lo.Ellipsis(t.Content, 200)}
+		}), nil
+	},
+})
+
+func normalizeTagName(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+// Checks a tag before it is saved, and sets its color from a hex string if one is given. Returns the response to send
+// if the tag is invalid.
+func validateTag(t *database.Tag, color string) *Response {
+	if !tagNamePattern.MatchString(t.Name) {
+		return &Response{Key: "tags.invalidName"}
+	}
+	if _, err := parseTag(t.Name, t.Content); err != nil {
+		return &Response{Key: "tags.invalidTemplate", Vars: &i18n.Vars{"error": err.Error()}}
+	}
+	if color != "" {
+		parsed, err := strconv.ParseUint(strings.TrimPrefix(color, "#"), 16, 24)
+		if err != nil {
+			return &Response{Key: "tags.invalidColor", Vars: &i18n.Vars{"color": color}}
+		}
+		t.EmbedColor = int(parsed)
+	}
+	return nil
+}
+
+// Parses the content of a tag. Loops are rejected, so rendering a tag takes time proportional to its length.
+func parseTag(name, content string) (*template.Template, error) {
+	templ, err := template.New(name).Funcs(template.FuncMap{"printf": tagPrintf}).Parse(content)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range templ.Templates() {
+		if t.Tree != nil && hasLoop(t.Tree.Root) {
+			return nil, errors.New("loops can't be used in tags")
+		}
+	}
+	return templ, nil
+}
+
+func hasLoop(node parse.Node) bool {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		return n != nil && slices.ContainsFunc(n.Nodes, hasLoop)
+	case *parse.RangeNode:
+		return true
+	case *parse.IfNode:
+		return hasLoop(n.List) || hasLoop(n.ElseList)
+	case *parse.WithNode:
+		return hasLoop(n.List) || hasLoop(n.ElseList)
+	}
+	return false
+}
+
+// Matches printf verbs with a width or precision from an argument or of 1000 or more, which could make huge strings
+var largePadding = regexp.MustCompile(`%[^a-zA-Z%]*(\*|\d{4,})`)
+
+// Like the printf template function, but with bounded padding
+func tagPrintf(format string, args ...any) (string, error) {
+	if largePadding.MatchString(format) {
+		return "", errors.New("printf widths and precisions must be under 1000")
+	}
+	return fmt.Sprintf(format, args...), nil
+}
+
+// Renders a tag, failing instead of returning partial content if the template errors, takes longer than
+// tagRenderTimeout, or writes more than maxTagLength characters
+func renderTag(templ *template.Template, vars *i18n.Vars) (string, error) {
+	out := &limitedWriter{limit: maxTagLength}
+	done := make(chan error, 1)
+	go func() { done <- templ.Execute(out, vars) }()
+	select {
+	case err := <-done:
+		if err != nil {
+			return "", err
+		}
+		return out.String(), nil
+	case <-time.After(tagRenderTimeout):
+		return "", errors.New("rendering took too long")
+	}
+}
+
+// Fails writes that would take it over limit characters
+type limitedWriter struct {
+	strings.Builder
+	runes int
+	limit int
+}
+
+func (w *limitedWriter) Write(p []byte) (int, error) {
+	w.runes += utf8.RuneCount(p)
+	if w.runes > w.limit {
+		return 0, errTagTooLong
+	}
+	return w.Builder.Write(p)
+}
+
+// Variables available in tag templates, such as {{ .target_mention }}
+func tagVars(cd *CommandData, target *dg.Member) *i18n.Vars {
+	vars := i18n.Vars{
+		"author":         cd.Member.DisplayName(),
+		"author_mention": cd.Member.Mention(),
+		"target":         target.DisplayName(),
+		"target_mention": target.Mention(),
+		"channel":        "<#" + cd.ChannelID + ">",
+		"now":            "<t:" + strconv.FormatInt(time.Now().Unix(), 10) + ":f>",
+	}
+	if guild, err := cd.State.Guild(cd.GuildID); err == nil {
+		vars["server"] = guild.Name
+	}
+	return &vars
+}
+
+func tagAllowedMentions(mentions database.TagMentions) *dg.MessageAllowedMentions {
+	switch mentions {
+	case database.TagMentionsUsers:
+		return &dg.MessageAllowedMentions{Parse: []dg.AllowedMentionType{dg.AllowedMentionTypeUsers}}
+	case database.TagMentionsRoles:
+		return &dg.MessageAllowedMentions{Parse: []dg.AllowedMentionType{dg.AllowedMentionTypeUsers, dg.AllowedMentionTypeRoles}}
+	case database.TagMentionsEveryone:
+		return &dg.MessageAllowedMentions{Parse: []dg.AllowedMentionType{dg.AllowedMentionTypeUsers, dg.AllowedMentionTypeRoles, dg.AllowedMentionTypeEveryone}}
+	}
+	return &dg.MessageAllowedMentions{Parse: []dg.AllowedMentionType{}}
+}
@@ -1,10 +1,10 @@
 package commands
 
 import (
-	"fmt"
 	"math/rand"
 	"snoozybot/internal/cooldown"
 	"snoozybot/internal/database"
+	"snoozybot/internal/features"
 	"snoozybot/internal/i18n"
 	"time"
 
@@ -16,13 +16,15 @@ var flip = BotCommand{
 	ApplicationCommand: dg.ApplicationCommand{
 		Name: "flip",
 	},
+	Feature:     features.Fun,
+	DeferPublic: true,
 	CommandHandler: func(cd *CommandData) error {
 		// 1% chance of the flip failing
 		if rand.Float32() < 0.01 {
 			return cd.Respond(Response{Key: "flip.failure", Public: true})
 		}
 
-		result := i18n.Get(*cd.Interaction.GuildLocale, lo.Ternary(rand.Float32() < 0.5, "flip.heads", "flip.tails"))
+		result := i18n.Get(cd.PublicLocale(), lo.Ternary(rand.Float32() < 0.5, "flip.heads", "flip.tails"))
 		return cd.Respond(Response{Key: "flip.success", Vars: &i18n.Vars{"result": result}, Public: true})
 	},
 }
@@ -34,6 +36,8 @@ var roll = BotCommand{
 			{Type: dg.ApplicationCommandOptionInteger, Name: "sides", Required: true},
 		},
 	},
+	Feature:     features.Fun,
+	DeferPublic: true,
 	CommandHandler: func(cd *CommandData) error {
 		sides := cd.Option("sides").IntValue()
 		if sides < 3 {
@@ -47,15 +51,17 @@ var roll = BotCommand{
 	},
 }
 
+type targetedOptions struct {
+	User *dg.Member `option:"user,required"`
+}
+
 func createTargetedCommand(name string) *BotCommand {
 	return &BotCommand{
-		ApplicationCommand: dg.ApplicationCommand{
-			Name: name,
-			Options: []*dg.ApplicationCommandOption{
-				{Type: dg.ApplicationCommandOptionUser, Name: "user", Required: true},
-			},
-		},
-		CommandHandler: handleTargetedCommand,
+		ApplicationCommand: dg.ApplicationCommand{Name: name},
+		Feature:            features.Fun,
+		DeferPublic:        true,
+		Middlewares:        []Middleware{Cooldown(cdm)},
+		Bind:               Bind(handleTargetedCommand),
 	}
 }
 
@@ -65,39 +71,36 @@ var cdm = cooldown.Initialize(cooldown.CooldownManager{
 	CooldownDuration:   time.Minute * 5,
 })
 
-func handleTargetedCommand(cd *CommandData) error {
-	// Check cooldown first
-	if !cdm.Can(cd.GuildID, cd.ChannelID, cd.Interaction.Member) {
-		return cd.Respond(Response{Key: "base.cooldown"})
-	}
-
-	user := cd.Option("user").UserValue(cd.Session)
-	if user == nil {
-		return fmt.Errorf("user not found")
-	}
+func handleTargetedCommand(cd *CommandData, opts *targetedOptions) error {
 	command := cd.ApplicationCommandData().Name
-	vars := i18n.GetFragments(*cd.Interaction.GuildLocale, command)
+	variant, target := actionTarget(cd, opts.User)
+	vars := i18n.GetFragments(cd.PublicLocale(), command)
 	vars["author"] = cd.Interaction.Member.DisplayName()
+	vars["target"] = target
+	key := command + "." + string(variant)
+	cd.Log.Debug().Str("key", key).Any("vars", vars).Msg("Responding to targeted command")
+	return respondTargeted(cd, Response{Key: key, Vars: &vars})
+}
 
-	var key string
-	if user.ID == cd.Interaction.Member.User.ID {
-		vars["target"] = cd.Interaction.Member.DisplayName()
-		key = command + ".self"
-	} else if user.ID == cd.State.User.ID {
+// Returns which variant of a targeted action applies to the member, and how to name them in the response
+func actionTarget(cd *CommandData, member *dg.Member) (database.ActionVariant, string) {
+	if member.User.ID == cd.Invoker().ID {
+		return database.ActionVariantSelf, cd.Interaction.Member.DisplayName()
+	} else if member.User.ID == cd.State.User.ID {
 		// The bot's name is supposed to be in GlobalName by discord docs but discord is broken
 		botMember, _ := cd.State.Member(cd.GuildID, cd.State.User.ID)
-		vars["target"] = lo.CoalesceOrEmpty(botMember.DisplayName(), cd.State.Application.Name, cd.State.User.Username)
-		key = command + ".bot"
-	} else {
-		vars["target"] = mentionIfWanted(lo.Must(cd.GuildMember(cd.GuildID, user.ID)))
-		key = command + ".user"
+		return database.ActionVariantBot, lo.CoalesceOrEmpty(botMember.DisplayName(), cd.State.Application.Name, cd.State.User.Username)
 	}
-	cd.Log.Debug().Str("key", key).Any("vars", vars).Msg("Responding to targeted command")
-	return cd.Respond(Response{Key: key, Vars: &vars, Public: true, InteractionResponseData: dg.InteractionResponseData{
-		AllowedMentions: &dg.MessageAllowedMentions{
-			Parse: []dg.AllowedMentionType{dg.AllowedMentionTypeUsers},
-		},
-	}})
+	return database.ActionVariantUser, mentionIfWanted(member)
+}
+
+// Sends the response of a targeted action to the channel. Only users can be pinged, and only if they want to be.
+func respondTargeted(cd *CommandData, r Response) error {
+	r.Public = true
+	r.AllowedMentions = &dg.MessageAllowedMentions{
+		Parse: []dg.AllowedMentionType{dg.AllowedMentionTypeUsers},
+	}
+	return cd.Respond(r)
 }
 
 func mentionIfWanted(target *dg.Member) string {
@@ -0,0 +1,68 @@
+package commands_test
+
+import (
+	"snoozybot/internal/config"
+	"snoozybot/internal/database"
+	"snoozybot/internal/i18n"
+	"snoozybot/internal/replay"
+	"strconv"
+	"strings"
+	"testing"
+
+	dg "github.com/bwmarrin/discordgo"
+)
+
+const guildID = "200000000000000001"
+
+func TestTargetedCommand(t *testing.T) {
+	author := &dg.Member{User: &dg.User{ID: "400000000000000001", Username: "author"}}
+	pingable := &dg.Member{User: &dg.User{ID: "400000000000000002", Username: "pingable"}}
+	quiet := &dg.Member{User: &dg.User{ID: "400000000000000003", Username: "quiet"}}
+	stranger := &dg.User{ID: "400000000000000004", Username: "stranger"}
+	database.UserCache.Add(pingable.User.ID, database.User{UserID: pingable.User.ID})
+	database.UserCache.Add(quiet.User.ID, database.User{UserID: quiet.User.ID, SuppressMentions: true})
+
+	tests := []struct {
+		name    string
+		target  string
+		want    []string
+		notWant []string
+	}{
+		{name: "member", target: pingable.User.ID, want: []string{"author", pingable.Mention()}},
+		{name: "member without mentions", target: quiet.User.ID, want: []string{"author", "quiet"}, notWant: []string{quiet.Mention()}},
+		{name: "self", target: author.User.ID, want: []string{"author"}, notWant: []string{author.Mention()}},
+		{name: "not a member", target: stranger.ID, want: []string{i18n.Get(dg.EnglishUS, "base.invalidOption", &i18n.Vars{"option": "user"})}},
+	}
+	for i, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			session := replay.NewSession()
+			session.AddGuild(&dg.Guild{ID: guildID, Name: "Replay", Members: []*dg.Member{author, pingable, quiet}})
+			config.FeaturesEnabled.Seed(guildID, []string{"fun"})
+			database.CommandRuleCache.Add(guildID, nil)
+			// a channel per test, so the channel cooldown doesn't kick in
+			channelID := "30000000000000000" + strconv.Itoa(i)
+
+			session.Interact(replay.CommandInteraction(guildID, channelID, author, "bap",
+				replay.TypedOption("user", dg.ApplicationCommandOptionUser, test.target)))
+
+			calls := session.API.Calls("POST /interactions/")
+			if len(calls) != 1 {
+				t.Fatalf("sent %d responses, want 1: %v", len(calls), session.API.Calls())
+			}
+			var response dg.InteractionResponse
+			if err := calls[0].Decode(&response); err != nil {
+				t.Fatal(err)
+			}
+			for _, want := range test.want {
+				if !strings.Contains(response.Data.Content, want) {
+					t.Errorf("response %q does not contain %q", response.Data.Content, want)
+				}
+			}
+			for _, notWant := range test.notWant {
+				if strings.Contains(response.Data.Content, notWant) {
+					t.Errorf("response %q contains %q", response.Data.Content, notWant)
+				}
+			}
+		})
+	}
+}
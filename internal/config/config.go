@@ -2,7 +2,12 @@ package config
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
 	"snoozybot/internal/database"
+	"strings"
 	"time"
 
 	"github.com/hashicorp/golang-lru/v2/expirable"
@@ -15,9 +20,37 @@ type GuildConfig[T any] string
 type ConfigValue[T any] struct {
 	*gorm.DB
 	*database.Config
+	key string
 }
 type GuildID interface{ ~uint64 | string }
 
+// A guild config key of any value type
+type Key interface {
+	IsSet(guild string) bool
+	String() string
+	Description() string
+	Feature() string
+	// Returns the default value as JSON, or "" if the key has no default
+	Default() string
+	TypeName() string
+	// Returns what the IDs of the key refer to, or "" if its values are not IDs
+	IDKind() IDKind
+	Schema() map[string]any
+	// Parses a value typed by a user into the value type of the key, and checks it with the key's validator
+	Parse(input string) (any, error)
+	// Decodes a JSON value into the value type of the key, and checks it with the key's validator
+	Decode(raw []byte) (any, error)
+	// Returns the guild's value as JSON, or "" if it is not set
+	Raw(guild string) string
+	// Writes the guild's value, which must have the value type of the key
+	Set(guild string, value any, actor string) error
+	Unset(guild string, actor string) error
+	// Drops the guild's cached value, so the next read comes from the database
+	Forget(guild string)
+	// Restores the value the guild had after a version in the key's history
+	Rollback(guild string, version uint, actor string) error
+}
+
 var cache = expirable.NewLRU[string, *ConfigValue[any]](512, nil, time.Hour)
 
 func (c GuildConfig[T]) Get(guild string) *ConfigValue[T] {
@@ -28,7 +61,7 @@ func (c GuildConfig[T]) Get(guild string) *ConfigValue[T] {
 	var record database.Config
 	result := database.Database.Where(&database.Config{ConfigKey: string(c), GuildID: guild}).Select("config_value").Take(&record)
 	log.Debug().Any("value", record.ConfigValue).Err(result.Error).Msg("Fetched guild config")
-	configValue := &ConfigValue[T]{result, &record}
+	configValue := &ConfigValue[T]{result, &record, string(c)}
 	cache.Add(cacheKey, (*ConfigValue[any])(configValue))
 	return configValue
 }
@@ -37,7 +70,7 @@ func (c GuildConfig[T]) GetAll() map[string]*ConfigValue[T] {
 	var records []database.Config
 	database.Database.Where(&database.Config{ConfigKey: string(c)}).Find(&records)
 	return lo.FromEntries(lo.Map(records, func(record database.Config, _ int) lo.Entry[string, *ConfigValue[T]] {
-		return lo.Entry[string, *ConfigValue[T]]{Key: record.GuildID, Value: &ConfigValue[T]{nil, &record}}
+		return lo.Entry[string, *ConfigValue[T]]{Key: record.GuildID, Value: &ConfigValue[T]{nil, &record, string(c)}}
 	}))
 }
 
@@ -47,7 +80,13 @@ func parseJSON[T any](s []byte) (T, error) {
 	return value, err
 }
 
+// Returns the guild's value, or the key's default if the guild has not set it
 func (cv *ConfigValue[T]) Value() (T, error) {
+	if cv.DB != nil && errors.Is(cv.DB.Error, gorm.ErrRecordNotFound) {
+		if defaultValue := GuildConfig[T](cv.key).Definition().Default; defaultValue != nil {
+			return *defaultValue, nil
+		}
+	}
 	if cv.DB != nil && cv.DB.Error != nil {
 		return *new(T), cv.DB.Error
 	}
@@ -58,6 +97,137 @@ func (cv *ConfigValue[T]) Exists() bool {
 	return cv.DB != nil && cv.DB.Error == nil
 }
 
+// Whether the key has a non-empty value in the guild
+func (c GuildConfig[T]) IsSet(guild string) bool {
+	cv := c.Get(guild)
+	if !cv.Exists() {
+		return false
+	}
+	value := strings.TrimSpace(string(cv.Config.ConfigValue))
+	return !slices.Contains([]string{"", "null", `""`, "[]", "{}"}, value)
+}
+
+func (c GuildConfig[T]) String() string {
+	return string(c)
+}
+
+// Sets a guild's value in the cache only, without writing it to the database. Used by the replay harness to
+// configure guilds without database rows. The value is dropped by ClearCache.
+func (c GuildConfig[T]) Seed(guild string, value T) {
+	raw, err := json.Marshal(value)
+	if err != nil {
+		log.Panic().Err(err).Str("key", string(c)).Msg("Cannot seed config value")
+	}
+	record := &database.Config{ConfigKey: string(c), GuildID: guild, ConfigValue: raw}
+	cache.Add(string(c)+":"+guild, &ConfigValue[any]{&gorm.DB{}, record, string(c)})
+}
+
+// Parses a value typed by a user. IDs can also be given as mentions like <#123>, and lists can be given as a JSON array
+// or separated by commas.
+func (c GuildConfig[T]) Parse(input string) (any, error) {
+	parsed, err := parseInput[T](strings.TrimSpace(input))
+	if err != nil {
+		return nil, err
+	}
+	value := parsed.(T)
+	if err := c.Validate(value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+func (c GuildConfig[T]) Decode(raw []byte) (any, error) {
+	value, err := parseJSON[T](raw)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.Validate(value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+func parseInput[T any](input string) (any, error) {
+	var value T
+	switch any(value).(type) {
+	case string:
+		return input, nil
+	case json.Number:
+		return parseID(input)
+	case []json.Number:
+		ids := []json.Number{}
+		for _, item := range splitList(input) {
+			id, err := parseID(item)
+			if err != nil {
+				return nil, err
+			}
+			ids = append(ids, id)
+		}
+		return ids, nil
+	case []string:
+		return splitList(input), nil
+	}
+	if err := json.Unmarshal([]byte(input), &value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+var idPattern = regexp.MustCompile(`^(\d+)$|^<(?:#|@&|@!?)(\d+)>$`)
+
+// Parses a discord ID, or the mention of a channel, role or user
+func parseID(input string) (json.Number, error) {
+	match := idPattern.FindStringSubmatch(strings.TrimSpace(input))
+	if match == nil {
+		return "", fmt.Errorf("%q is not an ID", input)
+	}
+	return json.Number(match[1] + match[2]), nil
+}
+
+// Splits a list of values, given either as a JSON array or separated by commas
+func splitList(input string) []string {
+	if strings.HasPrefix(input, "[") {
+		var items []any
+		decoder := json.NewDecoder(strings.NewReader(input))
+		// keeps IDs as they are written, instead of rounding them to floats
+		decoder.UseNumber()
+		if decoder.Decode(&items) == nil {
+			return lo.Map(items, func(item any, _ int) string { return fmt.Sprint(item) })
+		}
+	}
+	return lo.Compact(lo.Map(strings.Split(input, ","), func(item string, _ int) string { return strings.TrimSpace(item) }))
+}
+
+func (c GuildConfig[T]) Raw(guild string) string {
+	if !c.Get(guild).Exists() {
+		return ""
+	}
+	return string(c.Get(guild).Config.ConfigValue)
+}
+
+// Writes the guild's value to the database, records the change in the key's history and drops the cached value.
+// The actor is the user who made the change.
+func (c GuildConfig[T]) Set(guild string, value any, actor string) error {
+	typed, ok := value.(T)
+	if !ok {
+		return fmt.Errorf("value of %s must be a %T, not %T", c, typed, value)
+	}
+	raw, err := json.Marshal(typed)
+	if err != nil {
+		return err
+	}
+	return c.write(guild, raw, actor)
+}
+
+// Deletes the guild's value from the database, records the change in the key's history and drops the cached value
+func (c GuildConfig[T]) Unset(guild string, actor string) error {
+	return c.write(guild, nil, actor)
+}
+
+func (c GuildConfig[T]) Forget(guild string) {
+	cache.Remove(string(c) + ":" + guild)
+}
+
 func ClearCache() {
 	cache.Purge()
 }
@@ -0,0 +1,100 @@
+package config
+
+import (
+	"errors"
+	"reflect"
+	"snoozybot/internal/database"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrUnknownVersion = errors.New("no such version in the config history")
+
+// Writes a raw value, or deletes the value if raw is nil, and records the change as the next version of the key in
+// the guild. Writes that change nothing are not recorded.
+func (c GuildConfig[T]) write(guild string, raw []byte, actor string) error {
+	err := database.Database.Transaction(func(tx *gorm.DB) error {
+		return writeTx(tx, guild, string(c), raw, actor)
+	})
+	if err != nil {
+		return err
+	}
+	c.Forget(guild)
+	return nil
+}
+
+// Like write, within a transaction. The caller must drop the cached value once the transaction is committed.
+func writeTx(tx *gorm.DB, guild string, key string, raw []byte, actor string) error {
+	// locking the config row isn't enough, since there is none before the first write. Concurrent writes of the key
+	// would then both pick the same version.
+	if err := tx.Exec("SELECT pg_advisory_xact_lock(hashtext(?))", guild+":"+key).Error; err != nil {
+		return err
+	}
+	var current database.Config
+	if err := tx.Where(&database.Config{ConfigKey: key, GuildID: guild}).Take(&current).Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	if sameJSON(current.ConfigValue, raw) {
+		return nil
+	}
+	if raw == nil {
+		if err := tx.Delete(&database.Config{ConfigKey: key, GuildID: guild}).Error; err != nil {
+			return err
+		}
+	} else {
+		record := database.Config{ConfigKey: key, GuildID: guild, ConfigValue: raw}
+		if err := tx.Clauses(clause.OnConflict{UpdateAll: true}).Create(&record).Error; err != nil {
+			return err
+		}
+	}
+	var version uint
+	if err := tx.Model(&database.ConfigRevision{}).Where(&database.ConfigRevision{GuildID: guild, ConfigKey: key}).
+		Select("COALESCE(MAX(version), 0)").Scan(&version).Error; err != nil {
+		return err
+	}
+	return tx.Create(&database.ConfigRevision{
+		GuildID:   guild,
+		ConfigKey: key,
+		Version:   version + 1,
+		OldValue:  current.ConfigValue,
+		NewValue:  raw,
+		Actor:     actor,
+	}).Error
+}
+
+// Restores the value the guild had right after a version was written. The rollback is recorded as a new version, so it
+// can be rolled back too. Values that no longer fit the key, e.g. after its type changed, are rejected.
+func (c GuildConfig[T]) Rollback(guild string, version uint, actor string) error {
+	var revision database.ConfigRevision
+	if err := database.Database.Where(&database.ConfigRevision{GuildID: guild, ConfigKey: string(c), Version: version}).
+		Take(&revision).Error; errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrUnknownVersion
+	} else if err != nil {
+		return err
+	}
+	if IsNull(revision.NewValue) {
+		return c.Unset(guild, actor)
+	}
+	value, err := c.Decode(revision.NewValue)
+	if err != nil {
+		return err
+	}
+	return c.Set(guild, value, actor)
+}
+
+// Whether a stored value means the key is unset. Unset values are written as NULL, which reads back as JSON null.
+func IsNull(raw []byte) bool {
+	value := strings.TrimSpace(string(raw))
+	return value == "" || value == "null"
+}
+
+// Whether two JSON values are equal, ignoring formatting. Postgres reformats jsonb, so stored values rarely match the
+// bytes they were written with. Missing values are only equal to each other.
+func sameJSON(a, b []byte) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return reflect.DeepEqual(decodeJSON(a), decodeJSON(b))
+}
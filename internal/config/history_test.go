@@ -0,0 +1,71 @@
+package config
+
+import (
+	"encoding/json"
+	"os"
+	"snoozybot/internal/database"
+	"strconv"
+	"testing"
+	"time"
+
+	"gorm.io/datatypes"
+)
+
+func TestIsNull(t *testing.T) {
+	tests := []struct {
+		raw  []byte
+		want bool
+	}{
+		{raw: nil, want: true},
+		{raw: []byte{}, want: true},
+		{raw: datatypes.JSON("null"), want: true},
+		{raw: []byte(" null\n"), want: true},
+		{raw: []byte("0"), want: false},
+		{raw: []byte(`""`), want: false},
+		{raw: []byte("[]"), want: false},
+		{raw: []byte("123"), want: false},
+	}
+	for _, test := range tests {
+		if got := IsNull(test.raw); got != test.want {
+			t.Errorf("IsNull(%q) = %v, want %v", test.raw, got, test.want)
+		}
+	}
+}
+
+// Runs against the database at DATABASE_URL, in a guild of its own that is deleted afterwards
+func TestRollbackToUnset(t *testing.T) {
+	if _, ok := os.LookupEnv("DATABASE_URL"); !ok {
+		t.Skip("DATABASE_URL is not set")
+	}
+	if err := database.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	guild := "test-" + strconv.FormatInt(time.Now().UnixNano(), 10)
+	t.Cleanup(func() {
+		database.Database.Where(&database.Config{GuildID: guild}).Delete(&database.Config{})
+		database.Database.Where(&database.ConfigRevision{GuildID: guild}).Delete(&database.ConfigRevision{})
+	})
+	key := ReportChannelId
+
+	if err := key.Set(guild, json.Number("123"), "test"); err != nil { // version 1
+		t.Fatal(err)
+	}
+	if err := key.Unset(guild, "test"); err != nil { // version 2
+		t.Fatal(err)
+	}
+	if err := key.Set(guild, json.Number("456"), "test"); err != nil { // version 3
+		t.Fatal(err)
+	}
+	if err := key.Rollback(guild, 2, "test"); err != nil {
+		t.Fatal(err)
+	}
+	if key.IsSet(guild) || key.Raw(guild) != "" {
+		t.Errorf("rolled back to an unset version, but the value is %q", key.Raw(guild))
+	}
+	if err := key.Rollback(guild, 1, "test"); err != nil {
+		t.Fatal(err)
+	}
+	if got := key.Raw(guild); got != "123" {
+		t.Errorf("rolled back to version 1, but the value is %q, want 123", got)
+	}
+}
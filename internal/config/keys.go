@@ -1,42 +1,148 @@
 package config
 
-import "encoding/json"
+import (
+	"encoding/json"
+
+	"github.com/samber/lo"
+)
 
 const (
 	DiscordToken SecretConfig = "secret.discord.token"
 	OpenAiApiKey SecretConfig = "secret.openai.apikey"
 )
 
-const (
-	CooldownExemptChannels GuildConfig[[]json.Number] = "cooldown.exempt_channels"
-	ProfileBirthdayChannel GuildConfig[json.Number]   = "profile.birthday_channel"
-	LogsChannelID          GuildConfig[json.Number]   = "logs.channel_id"
+var (
+	CooldownExemptChannels = Define("cooldown.exempt_channels", Definition[[]json.Number]{
+		Description: "Channels where commands have no cooldown",
+		Feature:     "cooldown",
+		IDs:         IDChannel,
+	})
+	ProfileBirthdayChannel = Define("profile.birthday_channel", Definition[json.Number]{
+		Description: "Channel where birthdays are announced. Enables the birthday feature.",
+		Feature:     "birthday",
+		IDs:         IDChannel,
+	})
+	LogsChannelID = Define("logs.channel_id", Definition[json.Number]{
+		Description: "Channel where edited and deleted messages, bans, timeouts and members leaving are logged",
+		Feature:     "logs",
+		IDs:         IDChannel,
+	})
+	ErrorsChannelID = Define("errors.channel_id", Definition[json.Number]{
+		Description: "Channel where failed commands, handlers and tasks are reported. Should only be readable by moderators.",
+		Feature:     "errors",
+		IDs:         IDChannel,
+	})
+	FeaturesEnabled = Define("features.enabled", Definition[[]string]{
+		Description: "Names of the features to enable. Without it, every feature whose config is set is enabled.",
+		Feature:     "features",
+	})
 
-	ReportChannelId GuildConfig[json.Number] = "report.channel_id"
-	ReportMessage   GuildConfig[string]      = "report.message"
+	ReportChannelId = Define("report.channel_id", Definition[json.Number]{
+		Description: "Channel where /report sends reports. Enables the report feature.",
+		Feature:     "report",
+		IDs:         IDChannel,
+	})
+	ReportMessage = Define("report.message", Definition[string]{
+		Description: "Message for members who send a report",
+		Feature:     "report",
+	})
 
-	YoutubeNotifPlaylistIDs GuildConfig[[]string]    = "youtube.notif.playlist_ids"
-	YoutubeNotifChannelID   GuildConfig[json.Number] = "youtube.notif.channel_id"
-	YoutubeNotifTemplate    GuildConfig[string]      = "youtube.notif.title_template"
+	YoutubeNotifPlaylistIDs = Define("youtube.notif.playlist_ids", Definition[[]string]{
+		Description: "YouTube playlists to announce new videos of",
+		Feature:     "youtube",
+	})
+	YoutubeNotifChannelID = Define("youtube.notif.channel_id", Definition[json.Number]{
+		Description: "Channel where new videos are announced",
+		Feature:     "youtube",
+		IDs:         IDChannel,
+	})
+	YoutubeNotifTemplate = Define("youtube.notif.title_template", Definition[string]{
+		Description: "Template of the announcement of a new video",
+		Feature:     "youtube",
+		Validate:    validTemplate,
+	})
 
-	BskyNotifChannelID GuildConfig[json.Number] = "bsky.post_notif.channel_id"
-	BskyNotifUsers     GuildConfig[[]string]    = "bsky.post_notif.users"
-	BskyNotifTemplate  GuildConfig[string]      = "bsky.post_notif.title_template"
+	BskyNotifChannelID = Define("bsky.post_notif.channel_id", Definition[json.Number]{
+		Description: "Channel where new Bluesky posts are announced",
+		Feature:     "bsky",
+		IDs:         IDChannel,
+	})
+	BskyNotifUsers = Define("bsky.post_notif.users", Definition[[]string]{
+		Description: "Bluesky handles to announce new posts of",
+		Feature:     "bsky",
+	})
+	BskyNotifTemplate = Define("bsky.post_notif.title_template", Definition[string]{
+		Description: "Template of the announcement of a new post",
+		Feature:     "bsky",
+		Validate:    validTemplate,
+	})
 
-	TwitchLiveRoleID          GuildConfig[json.Number]   = "twitch.live_role_id"
-	TwitchLiveChannelID       GuildConfig[json.Number]   = "twitch.live_channel_id"
-	TwitchLiveEligibleRoleIDs GuildConfig[[]json.Number] = "twitch.live_eligible_role_ids"
-	TwitchLiveTemplate        GuildConfig[string]        = "twitch.live_template"
+	TwitchLiveRoleID = Define("twitch.live_role_id", Definition[json.Number]{
+		Description: "Role given to members while they stream on Twitch",
+		Feature:     "twitch",
+		IDs:         IDRole,
+	})
+	TwitchLiveChannelID = Define("twitch.live_channel_id", Definition[json.Number]{
+		Description: "Channel where members going live on Twitch are announced",
+		Feature:     "twitch",
+		IDs:         IDChannel,
+	})
+	TwitchLiveEligibleRoleIDs = Define("twitch.live_eligible_role_ids", Definition[[]json.Number]{
+		Description: "Roles a member needs to be announced. Without it, every member is announced.",
+		Feature:     "twitch",
+		IDs:         IDRole,
+	})
+	TwitchLiveTemplate = Define("twitch.live_template", Definition[string]{
+		Description: "Template of the announcement of a stream",
+		Feature:     "twitch",
+		Validate:    validTemplate,
+	})
 
-	RolesTempRoleID   GuildConfig[json.Number] = "roles.temp.role_id"
-	RolesTempDuration GuildConfig[uint]        = "roles.temp.duration_minutes"
+	RolesTempRoleID = Define("roles.temp.role_id", Definition[json.Number]{
+		Description: "Role given by the temporary role command. Enables the roles.temp feature.",
+		Feature:     "roles.temp",
+		IDs:         IDRole,
+	})
+	RolesTempDuration = Define("roles.temp.duration_minutes", Definition[uint]{
+		Description: "How long the temporary role lasts, in minutes",
+		Feature:     "roles.temp",
+		Validate:    positive,
+	})
 
-	RolesRegularsRoleID        GuildConfig[json.Number] = "roles.regulars.role_id"
-	RolesRegularsMinMessages   GuildConfig[uint]        = "roles.regulars.min_messages"
-	RolesRegularsMinDaysJoined GuildConfig[uint]        = "roles.regulars.min_days_joined"
-	RolesRegularsMinDaysActive GuildConfig[uint]        = "roles.regulars.min_days_active"
-	RolesRegularsAutoAssign    GuildConfig[bool]        = "roles.regulars.auto_assign"
+	RolesRegularsRoleID = Define("roles.regulars.role_id", Definition[json.Number]{
+		Description: "Role given to regular members. Enables the roles.regulars feature.",
+		Feature:     "roles.regulars",
+		IDs:         IDRole,
+	})
+	RolesRegularsMinMessages = Define("roles.regulars.min_messages", Definition[uint]{
+		Description: "Messages a member must have sent to become a regular. 0 means no minimum.",
+		Feature:     "roles.regulars",
+		Default:     lo.ToPtr[uint](0),
+	})
+	RolesRegularsMinDaysJoined = Define("roles.regulars.min_days_joined", Definition[uint]{
+		Description: "Days since joining a member needs to become a regular. 0 means no minimum.",
+		Feature:     "roles.regulars",
+		Default:     lo.ToPtr[uint](0),
+	})
+	RolesRegularsMinDaysActive = Define("roles.regulars.min_days_active", Definition[uint]{
+		Description: "Days with messages a member needs to become a regular. 0 means no minimum.",
+		Feature:     "roles.regulars",
+		Default:     lo.ToPtr[uint](0),
+	})
+	RolesRegularsAutoAssign = Define("roles.regulars.auto_assign", Definition[bool]{
+		Description: "Whether members get the regulars role automatically once they qualify",
+		Feature:     "roles.regulars",
+		Default:     lo.ToPtr(false),
+	})
 
-	ChatRoleIDs GuildConfig[[]json.Number] = "chat.role_ids"
-	ChatPrompts GuildConfig[[]string]      = "chat.prompts"
+	ChatRoleIDs = Define("chat.role_ids", Definition[[]json.Number]{
+		Description: "Roles whose members chat with the custom prompt instead of the default one",
+		Feature:     "chat",
+		IDs:         IDRole,
+	})
+	ChatPrompts = Define("chat.prompts", Definition[[]string]{
+		Description: "Lines of the custom chat prompt template",
+		Feature:     "chat",
+		Validate:    validTemplateLines,
+	})
 )
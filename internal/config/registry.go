@@ -0,0 +1,189 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"text/template"
+
+	"github.com/samber/lo"
+)
+
+// What a guild config key means and which values it accepts
+type Definition[T any] struct {
+	Description string
+	// The feature or area the key configures, e.g. "report" or "logs". Keys are grouped by it in the reference.
+	Feature string
+	// Used by ConfigValue.Value when the guild has not set the key. Keys without a default are unset until configured.
+	Default *T
+	// Rejects values that have the right type but make no sense for the key
+	Validate func(T) error
+	// What the IDs of the key refer to. Required for keys of IDs, so the bot can check them against the guild.
+	IDs IDKind
+}
+
+// What kind of Discord object the IDs of a key refer to
+type IDKind string
+
+const (
+	IDChannel IDKind = "channel"
+	IDRole    IDKind = "role"
+)
+
+// Every guild config key, in the order they are defined
+var GuildKeys []Key
+
+var definitions = map[string]any{}
+
+// Declares a guild config key. Keys are defined once, as package variables in keys.go.
+func Define[T any](name string, definition Definition[T]) GuildConfig[T] {
+	if _, ok := definitions[name]; ok {
+		panic("config key defined twice: " + name)
+	}
+	switch any(*new(T)).(type) {
+	case json.Number, []json.Number:
+		if definition.IDs == "" {
+			panic("config key of IDs defined without an ID kind: " + name)
+		}
+	}
+	key := GuildConfig[T](name)
+	definitions[name] = definition
+	GuildKeys = append(GuildKeys, key)
+	return key
+}
+
+// Returns the guild config key with the given name
+func FindKey(name string) (Key, bool) {
+	return lo.Find(GuildKeys, func(key Key) bool { return key.String() == name })
+}
+
+func (c GuildConfig[T]) Definition() Definition[T] {
+	definition, _ := definitions[string(c)].(Definition[T])
+	return definition
+}
+
+func (c GuildConfig[T]) Description() string {
+	return c.Definition().Description
+}
+
+func (c GuildConfig[T]) Feature() string {
+	return c.Definition().Feature
+}
+
+func (c GuildConfig[T]) Default() string {
+	if c.Definition().Default == nil {
+		return ""
+	}
+	return string(lo.Must(json.Marshal(*c.Definition().Default)))
+}
+
+func (c GuildConfig[T]) IDKind() IDKind {
+	return c.Definition().IDs
+}
+
+// Checks a value with the key's validator, if it has one
+func (c GuildConfig[T]) Validate(value T) error {
+	if validate := c.Definition().Validate; validate != nil {
+		return validate(value)
+	}
+	return nil
+}
+
+// Describes the values of the key as a JSON Schema
+func (c GuildConfig[T]) Schema() map[string]any {
+	schema := schemaOf(*new(T))
+	schema["description"] = c.Description()
+	if c.Definition().Default != nil {
+		schema["default"] = *c.Definition().Default
+	}
+	return schema
+}
+
+// Describes the value type of the key for people, e.g. "list of role IDs"
+func (c GuildConfig[T]) TypeName() string {
+	switch any(*new(T)).(type) {
+	case json.Number:
+		return string(c.IDKind()) + " ID"
+	case []json.Number:
+		return "list of " + string(c.IDKind()) + " IDs"
+	case string:
+		return "text"
+	case []string:
+		return "list of text"
+	case uint:
+		return "whole number"
+	case bool:
+		return "true or false"
+	}
+	return fmt.Sprintf("%T", *new(T))
+}
+
+func schemaOf(value any) map[string]any {
+	switch value.(type) {
+	case json.Number:
+		// IDs are stored as JSON numbers, but are too large for most JSON parsers to keep exactly
+		return map[string]any{"type": "integer", "minimum": 0}
+	case []json.Number:
+		return map[string]any{"type": "array", "items": schemaOf(json.Number(""))}
+	case string:
+		return map[string]any{"type": "string"}
+	case []string:
+		return map[string]any{"type": "array", "items": schemaOf("")}
+	case uint:
+		return map[string]any{"type": "integer", "minimum": 0}
+	case bool:
+		return map[string]any{"type": "boolean"}
+	}
+	return map[string]any{}
+}
+
+// Returns a JSON Schema for the config of one guild, as an object of key names to values
+func JSONSchema() ([]byte, error) {
+	properties := map[string]any{}
+	for _, key := range GuildKeys {
+		properties[key.String()] = key.Schema()
+	}
+	return json.MarshalIndent(map[string]any{
+		"$schema":              "https://json-schema.org/draft/2020-12/schema",
+		"title":                "Snoozybot guild config",
+		"type":                 "object",
+		"properties":           properties,
+		"additionalProperties": false,
+	}, "", "  ")
+}
+
+// Returns a Markdown reference of every guild config key, grouped by feature
+func Markdown() string {
+	var sb strings.Builder
+	sb.WriteString("# Guild config\n\nGenerated from the config registry by `snoozybot config docs`.\n")
+	groups := lo.GroupBy(GuildKeys, func(key Key) string { return key.Feature() })
+	names := lo.Keys(groups)
+	slices.Sort(names)
+	for _, feature := range names {
+		fmt.Fprintf(&sb, "\n## %s\n\n| Key | Type | Default | Description |\n| --- | --- | --- | --- |\n", lo.CoalesceOrEmpty(feature, "general"))
+		for _, key := range groups[feature] {
+			defaultValue := lo.Ternary(key.Default() == "", "", "`"+key.Default()+"`")
+			fmt.Fprintf(&sb, "| `%s` | %s | %s | %s |\n", key, key.TypeName(), defaultValue, strings.ReplaceAll(key.Description(), "|", `\|`))
+		}
+	}
+	return sb.String()
+}
+
+// Validators shared by several keys
+
+func validTemplate(text string) error {
+	_, err := template.New("").Parse(text)
+	return err
+}
+
+func validTemplateLines(lines []string) error {
+	return validTemplate(strings.Join(lines, "\n"))
+}
+
+func positive(value uint) error {
+	if value == 0 {
+		return fmt.Errorf("must be greater than 0")
+	}
+	return nil
+}
@@ -0,0 +1,171 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"snoozybot/internal/database"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+	"gorm.io/gorm"
+)
+
+// Returns the guild's config as a YAML document of key names to values. Only keys in GuildKeys are exported, so
+// secrets and keys the bot no longer knows stay out.
+func Export(guild string) ([]byte, error) {
+	var records []database.Config
+	if err := database.Database.Where(&database.Config{GuildID: guild}).Find(&records).Error; err != nil {
+		return nil, err
+	}
+	stored := map[string][]byte{}
+	for _, record := range records {
+		stored[record.ConfigKey] = record.ConfigValue
+	}
+	document := &yaml.Node{
+		Kind:        yaml.MappingNode,
+		HeadComment: fmt.Sprintf("Snoozybot config of guild %s, exported %s", guild, time.Now().UTC().Format(time.RFC3339)),
+	}
+	for _, key := range GuildKeys {
+		raw, ok := stored[key.String()]
+		if !ok {
+			continue
+		}
+		var value yaml.Node
+		if err := value.Encode(jsonToYAML(decodeJSON(raw))); err != nil {
+			return nil, err
+		}
+		document.Content = append(document.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: key.String()}, &value)
+	}
+	var out bytes.Buffer
+	encoder := yaml.NewEncoder(&out)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(document); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), encoder.Close()
+}
+
+// A change that importing a document makes to a key. Values are JSON, and "" when the key is not set.
+type Change struct {
+	Key   Key
+	Old   string
+	New   string
+	Value any // the new value with the value type of the key, or nil to unset the key
+}
+
+// Compares a YAML document like the ones from Export with the guild's config, and returns the changes importing it
+// would make. Every value is checked against the type and validator of its key. Keys missing from the document are
+// left as they are; keys set to null are unset.
+func PlanImport(guild string, document []byte) ([]Change, error) {
+	var values map[string]any
+	if err := yaml.Unmarshal(document, &values); err != nil {
+		return nil, err
+	}
+	var changes []Change
+	var problems []string
+	for _, key := range GuildKeys {
+		value, ok := values[key.String()]
+		if !ok {
+			continue
+		}
+		delete(values, key.String())
+		change := Change{Key: key, Old: key.Raw(guild)}
+		if value != nil {
+			raw, err := json.Marshal(value)
+			if err != nil {
+				problems = append(problems, fmt.Sprintf("%s: %s", key, err))
+				continue
+			}
+			if change.Value, err = key.Decode(raw); err != nil {
+				problems = append(problems, fmt.Sprintf("%s: %s", key, err))
+				continue
+			}
+			// as it will be stored, e.g. IDs written as strings become numbers
+			normalized, _ := json.Marshal(change.Value)
+			change.New = string(normalized)
+		}
+		if change.New == "" && change.Old == "" || change.New != "" && change.Old != "" && sameJSON([]byte(change.Old), []byte(change.New)) {
+			continue
+		}
+		changes = append(changes, change)
+	}
+	for _, name := range slices.Sorted(maps.Keys(values)) {
+		problems = append(problems, fmt.Sprintf("%s: unknown key", name))
+	}
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("invalid config document:\n%s", strings.Join(problems, "\n"))
+	}
+	return changes, nil
+}
+
+// Makes the changes from PlanImport in one transaction, so either all of them are made or none. Each change is
+// recorded in the history of its key.
+func ApplyImport(guild string, changes []Change, actor string) error {
+	err := database.Database.Transaction(func(tx *gorm.DB) error {
+		for _, change := range changes {
+			var raw []byte
+			if change.Value != nil {
+				raw = []byte(change.New)
+			}
+			if err := writeTx(tx, guild, change.Key.String(), raw, actor); err != nil {
+				return fmt.Errorf("%s: %w", change.Key, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, change := range changes {
+		change.Key.Forget(guild)
+	}
+	return nil
+}
+
+// Formats changes as a diff, with a line for the old and the new value of each key
+func FormatDiff(changes []Change) string {
+	var sb strings.Builder
+	for _, change := range changes {
+		if change.Old != "" {
+			fmt.Fprintf(&sb, "- %s: %s\n", change.Key, change.Old)
+		}
+		if change.New != "" {
+			fmt.Fprintf(&sb, "+ %s: %s\n", change.Key, change.New)
+		}
+	}
+	return sb.String()
+}
+
+func decodeJSON(raw []byte) (value any) {
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	// keeps IDs as they are written, instead of rounding them to floats
+	decoder.UseNumber()
+	decoder.Decode(&value)
+	return value
+}
+
+// Replaces JSON numbers with integers where possible, so YAML writes them as plain numbers instead of strings
+func jsonToYAML(value any) any {
+	switch v := value.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		if f, err := v.Float64(); err == nil {
+			return f
+		}
+	case []any:
+		for i := range v {
+			v[i] = jsonToYAML(v[i])
+		}
+	case map[string]any:
+		for k := range v {
+			v[k] = jsonToYAML(v[k])
+		}
+	}
+	return value
+}
@@ -7,3 +7,4 @@ import (
 )
 
 var UserCache = expirable.NewLRU[string, User](512, nil, time.Hour*24)
+var CommandRuleCache = expirable.NewLRU[string, []CommandRule](256, nil, time.Hour)
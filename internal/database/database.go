@@ -1,6 +1,11 @@
 package database
 
 import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
 	"os"
 
 	_ "snoozybot/internal/log"
@@ -14,19 +19,46 @@ import (
 	"gorm.io/gorm"
 )
 
-var Database *gorm.DB
+// Returned by queries made before Connect
+var ErrNotConnected = errors.New("not connected to the database")
 
-func init() {
-	dbUrl := lo.Must(os.LookupEnv("DATABASE_URL"))
+// Until Connect is called, queries fail with ErrNotConnected, so packages can be loaded without a database, e.g. to
+// replay handlers in tests.
+var Database = lo.Must(gorm.Open(postgres.New(postgres.Config{Conn: sql.OpenDB(notConnected{})}), &gorm.Config{
+	Logger:               newLogger(),
+	TranslateError:       true,
+	DisableAutomaticPing: true,
+}))
+
+// Connects to the database at DATABASE_URL and migrates it
+func Connect() error {
+	dbUrl, ok := os.LookupEnv("DATABASE_URL")
+	if !ok {
+		return errors.New("DATABASE_URL is not set")
+	}
 	log.Debug().Msg("Loaded database URL")
-	var logger = gormzerolog.NewGormLogger().WithInfo(func() gormzerolog.Event {
+	db, err := gorm.Open(postgres.Open(dbUrl), &gorm.Config{Logger: newLogger(), TranslateError: true})
+	if err != nil {
+		return err
+	}
+	if err := db.AutoMigrate(&Config{}, &ConfigRevision{}, &User{}, &ScheduledTask{}, &Quote{}, &MessageMetric{}, &CommandRule{}, &Tag{}, &ActionText{}); err != nil {
+		return fmt.Errorf("failed to run database migration: %w", err)
+	}
+	Database = db
+	return nil
+}
+
+func newLogger() *gormzerolog.GormLogger {
+	logger := gormzerolog.NewGormLogger().WithInfo(func() gormzerolog.Event {
 		return &gormzerolog.GormLoggerEvent{Event: log.Debug()}
 	})
 	logger.IgnoreRecordNotFoundError(true)
+	return logger
+}
 
-	Database = lo.Must(gorm.Open(postgres.Open(dbUrl), &gorm.Config{Logger: logger, TranslateError: true}))
+// A database driver that fails to connect
+type notConnected struct{}
 
-	if err := Database.AutoMigrate(&Config{}, &User{}, &ScheduledTask{}, &Quote{}, &MessageMetric{}); err != nil {
-		log.Fatal().Err(err).Msg("Failed to run database migration.")
-	}
-}
+func (notConnected) Connect(context.Context) (driver.Conn, error) { return nil, ErrNotConnected }
+func (notConnected) Open(string) (driver.Conn, error)             { return nil, ErrNotConnected }
+func (d notConnected) Driver() driver.Driver                      { return d }
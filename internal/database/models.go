@@ -14,6 +14,18 @@ type Config struct {
 	ConfigValue datatypes.JSON
 }
 
+// One change of a guild config key made through the config package. A null value means the key was not set.
+type ConfigRevision struct {
+	ID        uint   `gorm:"primarykey;autoIncrement"`
+	GuildID   string `gorm:"uniqueIndex:guild_key_version"`
+	ConfigKey string `gorm:"uniqueIndex:guild_key_version"`
+	Version   uint   `gorm:"uniqueIndex:guild_key_version"` // counts up from 1 for each guild and key
+	OldValue  datatypes.JSON
+	NewValue  datatypes.JSON
+	Actor     string // the user who made the change
+	CreatedAt time.Time
+}
+
 type User struct {
 	UserID              string `gorm:"primarykey"`
 	Timezone            *string
@@ -42,6 +54,9 @@ const (
 type ScheduledTaskReminderPayload struct {
 	ChannelID string `json:"channel"`
 	Reason    string `json:"reason"`
+	// Reminders set outside of guilds are sent by DM, from the application they were set with, in the user's locale
+	ApplicationID string `json:"application,omitempty"`
+	Locale        string `json:"locale,omitempty"`
 }
 
 type ScheduledTaskBirthdayPayload struct{}
@@ -67,6 +82,67 @@ type MessageMetric struct {
 	LastDistinctDayBoundary time.Time
 }
 
+type CommandRuleTarget string
+
+const (
+	CommandRuleChannel CommandRuleTarget = "channel"
+	CommandRuleRole    CommandRuleTarget = "role"
+)
+
+// Allows or denies a command and its subcommands in a channel or for a role
+type CommandRule struct {
+	GuildID     string            `gorm:"primaryKey"`
+	CommandPath string            `gorm:"primaryKey"` // e.g. "quotes/find"
+	TargetType  CommandRuleTarget `gorm:"primaryKey"`
+	TargetID    string            `gorm:"primaryKey"`
+	Allow       bool
+}
+
+// Who a tag may ping when it is posted
+type TagMentions string
+
+const (
+	TagMentionsNone     TagMentions = "none"
+	TagMentionsUsers    TagMentions = "users"
+	TagMentionsRoles    TagMentions = "roles" // users and roles
+	TagMentionsEveryone TagMentions = "everyone"
+)
+
+// A custom response defined by a guild's moderators and posted with /tag
+type Tag struct {
+	GuildID    string `gorm:"primaryKey"`
+	Name       string `gorm:"primaryKey"`
+	Content    string // a text/template
+	Embed      bool   // post the content as the description of an embed
+	EmbedTitle string
+	EmbedColor int
+	Mentions   TagMentions `gorm:"default:'none'"`
+	CreatedBy  string
+	UpdatedAt  time.Time
+}
+
+type ActionVariant string
+
+const (
+	ActionVariantSelf     ActionVariant = "self"     // the author targets themselves
+	ActionVariantBot      ActionVariant = "bot"      // the author targets the bot
+	ActionVariantUser     ActionVariant = "user"     // the author targets someone else
+	ActionVariantFragment ActionVariant = "fragment" // a value for the {{ .<Fragment> }} variable
+)
+
+// One text of a targeted action defined by a guild's moderators and posted with /action. A text is picked at random
+// from the texts of the action with the same locale and variant.
+type ActionText struct {
+	ID       uint   `gorm:"primarykey;autoIncrement"`
+	GuildID  string `gorm:"index:guild_action"`
+	Action   string `gorm:"index:guild_action"`
+	Locale   string
+	Variant  ActionVariant
+	Fragment string // name of the variable, only for fragments
+	Text     string // a text/template, except for fragments
+	AddedBy  string
+}
+
 func GetUser(id string) (*User, error) {
 	if user, ok := UserCache.Get(id); ok {
 		return &user, nil
@@ -78,3 +154,16 @@ func GetUser(id string) (*User, error) {
 	UserCache.Add(id, user)
 	return &user, nil
 }
+
+// Returns all command rules of a guild. Rules are cached; remove the guild from CommandRuleCache after changing them.
+func GetCommandRules(guildID string) ([]CommandRule, error) {
+	if rules, ok := CommandRuleCache.Get(guildID); ok {
+		return rules, nil
+	}
+	var rules []CommandRule
+	if err := Database.Where(&CommandRule{GuildID: guildID}).Find(&rules).Error; err != nil {
+		return nil, err
+	}
+	CommandRuleCache.Add(guildID, rules)
+	return rules, nil
+}
@@ -0,0 +1,198 @@
+package errorreport
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"snoozybot/internal/config"
+	"strings"
+	"sync"
+	"time"
+
+	dg "github.com/bwmarrin/discordgo"
+	"github.com/rs/zerolog/log"
+)
+
+// Only one report is sent per guild, source and name in this window. Later ones are counted and summarized in the
+// next report.
+const rateLimitWindow = 5 * time.Minute
+
+const maxStackFrames = 8
+
+type Source string
+
+const (
+	SourceCommand Source = "command"
+	SourceEvent   Source = "event"
+	SourceTask    Source = "task"
+)
+
+type Report struct {
+	ID      string // correlation ID, shown to the user and written to the logs
+	Source  Source
+	Name    string // command path, event handler or task name
+	GuildID string
+	UserID  string
+	Err     error
+	Stack   []byte // raw output of debug.Stack(); only set for panics
+}
+
+type limit struct {
+	sentAt     time.Time
+	suppressed int
+}
+
+var (
+	limitsMu sync.Mutex
+	limits   = make(map[string]*limit) // guild:source:name -> limit
+)
+
+// Secrets that may appear in error messages from HTTP clients
+var secretPatterns = map[*regexp.Regexp]string{
+	regexp.MustCompile(`(?i)\b(bot|bearer)\s+[\w.\-]{20,}`):                     "$1 [redacted]",
+	regexp.MustCompile(`(?i)\b(key|token|access_token|client_secret)=[^&\s"]+`): "$1=[redacted]",
+}
+
+// Creates a short random ID to correlate user-facing errors with logs and reports.
+func NewID() string {
+	b := make([]byte, 6)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Converts a recovered panic value to an error.
+func PanicError(rec any) error {
+	if err, ok := rec.(error); ok {
+		return err
+	}
+	return fmt.Errorf("panic: %v", rec)
+}
+
+// Posts the report to the guild's error channel, if one is configured and the report is not rate limited.
+func Send(s *dg.Session, r Report) {
+	if r.GuildID == "" {
+		return
+	}
+	channel, err := config.ErrorsChannelID.Get(r.GuildID).Value()
+	if err != nil {
+		return
+	}
+	suppressed, ok := allow(fmt.Sprintf("%s:%s:%s", r.GuildID, r.Source, r.Name))
+	if !ok {
+		return
+	}
+	_, err = s.ChannelMessageSendComplex(string(channel), &dg.MessageSend{
+		Embeds:          []*dg.MessageEmbed{r.embed(suppressed)},
+		AllowedMentions: &dg.MessageAllowedMentions{},
+	})
+	if err != nil {
+		log.Warn().Err(err).Str("guild", r.GuildID).Str("id", r.ID).Msg("Failed to send error report")
+	}
+}
+
+// Returns whether a report may be sent for key, and how many reports were suppressed since the last one.
+func allow(key string) (int, bool) {
+	limitsMu.Lock()
+	defer limitsMu.Unlock()
+	l, ok := limits[key]
+	if !ok {
+		l = &limit{}
+		limits[key] = l
+	}
+	if time.Since(l.sentAt) < rateLimitWindow {
+		l.suppressed++
+		return 0, false
+	}
+	suppressed := l.suppressed
+	l.sentAt = time.Now()
+	l.suppressed = 0
+	return suppressed, true
+}
+
+// An error that concerns a single guild, e.g. a notification that could not be sent to its channel. Tasks return
+// these so their errors are reported to that guild only.
+type GuildError struct {
+	GuildID string
+	Err     error
+}
+
+func (e *GuildError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *GuildError) Unwrap() error {
+	return e.Err
+}
+
+// Marks an error as concerning a single guild. Errors without a guild, e.g. of DM reminders, are returned as they are.
+func ForGuild(guildID string, err error) error {
+	if err == nil || guildID == "" {
+		return err
+	}
+	return &GuildError{GuildID: guildID, Err: err}
+}
+
+func (r *Report) embed(suppressed int) *dg.MessageEmbed {
+	fields := []*dg.MessageEmbedField{
+		{Name: "Source", Value: string(r.Source), Inline: true},
+		{Name: "Name", Value: r.Name, Inline: true},
+		{Name: "Correlation ID", Value: "`" + r.ID + "`", Inline: true},
+	}
+	if r.UserID != "" {
+		fields = append(fields, &dg.MessageEmbedField{Name: "User", Value: fmt.Sprintf("<@%s>", r.UserID), Inline: true})
+	}
+	fields = append(fields, &dg.MessageEmbedField{Name: "Error", Value: codeBlock(sanitize(fmt.Sprint(r.Err)))})
+	if len(r.Stack) > 0 {
+		fields = append(fields, &dg.MessageEmbedField{Name: "Stack", Value: codeBlock(shortenStack(r.Stack))})
+	}
+	embed := &dg.MessageEmbed{
+		Title:     "Error Report",
+		Color:     0xED4245,
+		Fields:    fields,
+		Timestamp: time.Now().Format(time.RFC3339),
+	}
+	if suppressed > 0 {
+		embed.Footer = &dg.MessageEmbedFooter{Text: fmt.Sprintf("%d similar errors were not reported", suppressed)}
+	}
+	return embed
+}
+
+func sanitize(s string) string {
+	for pattern, replacement := range secretPatterns {
+		s = pattern.ReplaceAllString(s, replacement)
+	}
+	return s
+}
+
+// Embed field values are limited to 1024 characters
+func codeBlock(s string) string {
+	s = strings.ReplaceAll(s, "```", "'''")
+	if len(s) > 1000 {
+		s = s[:1000] + "…"
+	}
+	return "```\n" + s + "\n```"
+}
+
+// Keeps only the bot's own frames from a goroutine stack, as "function (file:line)".
+func shortenStack(stack []byte) string {
+	lines := strings.Split(strings.TrimSpace(string(stack)), "\n")
+	var frames []string
+	for i := 1; i+1 < len(lines) && len(frames) < maxStackFrames; i += 2 {
+		function := lines[i]
+		if !strings.HasPrefix(function, "snoozybot/") && !strings.HasPrefix(function, "main.") ||
+			strings.HasPrefix(function, "snoozybot/internal/errorreport.") {
+			continue
+		}
+		if args := strings.LastIndex(function, "("); args > 0 {
+			function = function[:args]
+		}
+		location := strings.TrimSpace(lines[i+1])
+		location = location[strings.LastIndex(location, "/")+1:]
+		if offset := strings.Index(location, " +0x"); offset > 0 {
+			location = location[:offset]
+		}
+		frames = append(frames, fmt.Sprintf("%s (%s)", strings.TrimPrefix(function, "snoozybot/internal/"), location))
+	}
+	return strings.Join(frames, "\n")
+}
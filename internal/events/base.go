@@ -2,10 +2,15 @@ package events
 
 import (
 	"runtime/debug"
+	"snoozybot/internal/background"
+	"snoozybot/internal/errorreport"
+	"snoozybot/internal/metrics"
+	"time"
 
 	dg "github.com/bwmarrin/discordgo"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
+	"github.com/samber/lo"
 )
 
 type EventData[T any] struct {
@@ -14,17 +19,74 @@ type EventData[T any] struct {
 	Logger  *zerolog.Logger
 }
 
-func createEventHandler[T any](name string, handler func(ed EventData[T]) error) func(s *dg.Session, ev *T) {
+// An event handler to be registered on every bot session, along with the gateway intents it needs to receive its events.
+type EventHandler struct {
+	Name    string
+	Intents dg.Intent
+	Handler any
+}
+
+func createEventHandler[T any](name string, intents dg.Intent, handler func(ed EventData[T]) error) *EventHandler {
 	logger := log.Logger.With().Str("event", name).Logger()
-	return func(s *dg.Session, ev *T) {
+	return &EventHandler{Name: name, Intents: intents, Handler: func(s *dg.Session, ev *T) {
+		done, ok := background.Track("event:" + name)
+		if !ok {
+			return
+		}
+		defer done()
+		start := time.Now()
+		outcome := metrics.OutcomePanic
+		defer func() {
+			metrics.EventDuration.WithLabelValues(name).Observe(time.Since(start).Seconds())
+			metrics.Events.WithLabelValues(name, outcome).Inc()
+		}()
 		defer func() {
 			if rec := recover(); rec != nil {
-				logger.Error().Err(rec.(error)).Bytes("stack", debug.Stack()).Msg("Captured panic during event handling")
+				reportEventError(s, name, ev, &logger, errorreport.PanicError(rec), debug.Stack())
 			}
 		}()
 		err := handler(EventData[T]{Session: s, Event: ev, Logger: &logger})
+		outcome = metrics.Outcome(err)
 		if err != nil {
-			logger.Error().Err(err).Msg("Error handling event")
+			reportEventError(s, name, ev, &logger, err, nil)
 		}
+	}}
+}
+
+func reportEventError(s *dg.Session, name string, ev any, logger *zerolog.Logger, err error, stack []byte) {
+	id := errorreport.NewID()
+	if stack != nil {
+		logger.Error().Str("id", id).Err(err).Bytes("stack", stack).Msg("Captured panic during event handling")
+	} else {
+		logger.Error().Str("id", id).Err(err).Msg("Error handling event")
+	}
+	guildID, userID := eventSource(ev)
+	errorreport.Send(s, errorreport.Report{ID: id, Source: errorreport.SourceEvent, Name: name, GuildID: guildID, UserID: userID, Err: err, Stack: stack})
+}
+
+// Returns the guild and user (if any) that an event belongs to
+func eventSource(ev any) (guildID string, userID string) {
+	switch ev := ev.(type) {
+	case *dg.MessageCreate:
+		return ev.GuildID, lo.TernaryF(ev.Author == nil, lo.Empty[string], func() string { return ev.Author.ID })
+	case *dg.MessageUpdate:
+		return ev.GuildID, lo.TernaryF(ev.Author == nil, lo.Empty[string], func() string { return ev.Author.ID })
+	case *dg.MessageDelete:
+		return ev.GuildID, ""
+	case *dg.GuildCreate:
+		return ev.ID, ""
+	case *dg.GuildMemberRemove:
+		return ev.GuildID, ev.User.ID
+	case *dg.GuildMemberUpdate:
+		return ev.GuildID, ev.User.ID
+	case *dg.GuildBanAdd:
+		return ev.GuildID, ev.User.ID
+	case *dg.GuildBanRemove:
+		return ev.GuildID, ev.User.ID
+	case *dg.PresenceUpdate:
+		return ev.GuildID, ev.User.ID
+	case *dg.GuildAuditLogEntryCreate:
+		return ev.GuildID, ev.UserID
 	}
+	return "", ""
 }
@@ -0,0 +1,99 @@
+package events_test
+
+import (
+	"os"
+	"snoozybot/internal/database"
+	"snoozybot/internal/replay"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	dg "github.com/bwmarrin/discordgo"
+	"github.com/samber/lo"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+const (
+	guildID   = "200000000000000001"
+	channelID = "300000000000000001"
+)
+
+func TestMain(m *testing.M) {
+	// handlers save what they change; the statements are built but not run
+	database.Database = database.Database.Session(&gorm.Session{DryRun: true, SkipDefaultTransaction: true})
+	os.Exit(m.Run())
+}
+
+// Returns a bedtime that was the given time ago, in UTC
+func bedtimeAgo(ago time.Duration) *datatypes.Time {
+	t := time.Now().UTC().Add(-ago)
+	return lo.ToPtr(datatypes.NewTime(t.Hour(), t.Minute(), t.Second(), 0))
+}
+
+func TestBedtimeHandler(t *testing.T) {
+	tests := []struct {
+		name   string
+		bot    bool
+		user   database.User
+		notify bool
+	}{
+		{name: "no bedtime", user: database.User{Timezone: lo.ToPtr("UTC")}},
+		{name: "no timezone", user: database.User{Bedtime: bedtimeAgo(time.Hour)}},
+		{name: "bot author", bot: true, user: database.User{Timezone: lo.ToPtr("UTC"), Bedtime: bedtimeAgo(time.Hour)}},
+		{name: "unknown timezone", user: database.User{Timezone: lo.ToPtr("Mars/Olympus_Mons"), Bedtime: bedtimeAgo(time.Hour)}},
+		{name: "before bedtime", user: database.User{Timezone: lo.ToPtr("UTC"), Bedtime: bedtimeAgo(-time.Hour)}},
+		{name: "early in the night", user: database.User{Timezone: lo.ToPtr("UTC"), Bedtime: bedtimeAgo(time.Hour)}, notify: true},
+		{name: "late in the night", user: database.User{Timezone: lo.ToPtr("UTC"), Bedtime: bedtimeAgo(4 * time.Hour)}, notify: true},
+		{name: "after the night", user: database.User{Timezone: lo.ToPtr("UTC"), Bedtime: bedtimeAgo(7 * time.Hour)}},
+		{name: "notified recently", user: database.User{
+			Timezone:            lo.ToPtr("UTC"),
+			Bedtime:             bedtimeAgo(time.Hour),
+			LastBedtimeNotified: lo.ToPtr(time.Now().Add(-10 * time.Minute)),
+		}},
+		{name: "notified before the cooldown", user: database.User{
+			Timezone:            lo.ToPtr("UTC"),
+			Bedtime:             bedtimeAgo(time.Hour),
+			LastBedtimeNotified: lo.ToPtr(time.Now().Add(-time.Hour)),
+		}, notify: true},
+	}
+	for i, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			session := replay.NewSession()
+			session.AddGuild(&dg.Guild{ID: guildID, Name: "Replay"})
+			author := &dg.User{ID: "40000000000000000" + strconv.Itoa(i), Username: "sleepy", Bot: test.bot}
+			test.user.UserID = author.ID
+			database.UserCache.Add(author.ID, test.user)
+
+			session.Dispatch(&dg.MessageCreate{Message: &dg.Message{
+				ID:        "500000000000000001",
+				GuildID:   guildID,
+				ChannelID: channelID,
+				Author:    author,
+				Content:   "one more game",
+			}}, "bedtime")
+
+			calls := session.API.Calls("POST /channels/" + channelID + "/messages")
+			if !test.notify {
+				if len(calls) != 0 {
+					t.Fatalf("sent %d messages, want none", len(calls))
+				}
+				return
+			}
+			if len(calls) != 1 {
+				t.Fatalf("sent %d messages, want 1", len(calls))
+			}
+			var message dg.MessageSend
+			if err := calls[0].Decode(&message); err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(message.Content, author.Mention()) {
+				t.Errorf("message %q does not mention the author", message.Content)
+			}
+			if user, _ := database.UserCache.Get(author.ID); user.LastBedtimeNotified == nil || time.Since(*user.LastBedtimeNotified) > time.Minute {
+				t.Errorf("last notification not updated: %v", user.LastBedtimeNotified)
+			}
+		})
+	}
+}
@@ -5,17 +5,16 @@ import (
 	_ "embed"
 	"encoding/json"
 	"fmt"
-	"os"
 	"snoozybot/internal/config"
 	"snoozybot/internal/cooldown"
 	"snoozybot/internal/i18n"
+	"snoozybot/internal/metrics"
 	"strings"
 	"text/template"
 	"time"
 
 	dg "github.com/bwmarrin/discordgo"
 	"github.com/openai/openai-go/v2"
-	"github.com/openai/openai-go/v2/option"
 	"github.com/openai/openai-go/v2/responses"
 	"github.com/rs/zerolog"
 	"github.com/samber/lo"
@@ -24,7 +23,9 @@ import (
 //go:embed chat.defaultprompt.txt
 var defaultPrompt string
 
-var client = openai.NewClient(option.WithAPIKey(lo.Must(os.LookupEnv("OPENAI_API_KEY"))))
+// Reads the API key from OPENAI_API_KEY. Without it, requests fail instead of the package, so handlers can be replayed
+// in tests.
+var client = openai.NewClient()
 
 var cdm = cooldown.Initialize(cooldown.CooldownManager{
 	UserInvocations:    2,
@@ -149,12 +150,14 @@ func getAIResponse(prompt string, history []*dg.Message, log *zerolog.Logger) (s
 	}), "\n")
 
 	log.Debug().Str("system", prompt).Str("message", messagePrompt).Msg("Sending message to AI.")
+	start := time.Now()
 	resp, err := client.Responses.New(context.Background(), responses.ResponseNewParams{
 		Model:           openai.ChatModelGPT5ChatLatest,
 		Instructions:    openai.String(prompt),
 		Input:           responses.ResponseNewParamsInputUnion{OfString: openai.String(messagePrompt)},
 		MaxOutputTokens: openai.Int(300),
 	})
+	metrics.ObserveAPI("openai", "responses.create", start, err)
 	if err != nil {
 		return "", err
 	}
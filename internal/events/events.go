@@ -1,18 +1,20 @@
 package events
 
-var Events = []any{
-	createEventHandler("bedtime", bedtimeHandler),
-	createEventHandler("memberLeaveCleanup", memberLeaveCleanup),
-	createEventHandler("twitchStreamGuildAvailable", twitchStreamGuildAvailable),
-	createEventHandler("twitchStreamPresenceUpdate", twitchStreamPresenceUpdate),
-	createEventHandler("roleMessageMetricsHandler", roleMessageMetricsHandler),
-	createEventHandler("chatMessageCreate", chatMessageCreate),
-	createEventHandler("logMessageCreate", logMessageCreate),
-	createEventHandler("logMessageUpdate", logMessageUpdate),
-	createEventHandler("logMessageDelete", logMessageDelete),
-	createEventHandler("logBan", logBan),
-	createEventHandler("logUnban", logUnban),
-	createEventHandler("logLeave", logLeave),
-	createEventHandler("logTimeout", logTimeout),
-	createEventHandler("logAuditLog", logAuditLog),
+import dg "github.com/bwmarrin/discordgo"
+
+var Events = []*EventHandler{
+	createEventHandler("bedtime", dg.IntentGuildMessages, bedtimeHandler),
+	createEventHandler("memberLeaveCleanup", dg.IntentGuildMembers, memberLeaveCleanup),
+	createEventHandler("twitchStreamGuildAvailable", dg.IntentGuilds|dg.IntentGuildPresences, twitchStreamGuildAvailable),
+	createEventHandler("twitchStreamPresenceUpdate", dg.IntentGuildPresences, twitchStreamPresenceUpdate),
+	createEventHandler("roleMessageMetricsHandler", dg.IntentGuildMessages, roleMessageMetricsHandler),
+	createEventHandler("chatMessageCreate", dg.IntentGuildMessages|dg.IntentMessageContent, chatMessageCreate),
+	createEventHandler("logMessageCreate", dg.IntentGuildMessages|dg.IntentMessageContent, logMessageCreate),
+	createEventHandler("logMessageUpdate", dg.IntentGuildMessages|dg.IntentMessageContent, logMessageUpdate),
+	createEventHandler("logMessageDelete", dg.IntentGuildMessages|dg.IntentMessageContent, logMessageDelete),
+	createEventHandler("logBan", dg.IntentGuildModeration, logBan),
+	createEventHandler("logUnban", dg.IntentGuildModeration, logUnban),
+	createEventHandler("logLeave", dg.IntentGuildMembers, logLeave),
+	createEventHandler("logTimeout", dg.IntentGuildMembers, logTimeout),
+	createEventHandler("logAuditLog", dg.IntentGuildModeration, logAuditLog),
 }
@@ -0,0 +1,184 @@
+package events_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"snoozybot/internal/config"
+	"snoozybot/internal/replay"
+	"strconv"
+	"testing"
+	"time"
+
+	dg "github.com/bwmarrin/discordgo"
+	"github.com/samber/lo"
+)
+
+var logHandlers = []string{
+	"logMessageCreate", "logMessageUpdate", "logMessageDelete", "logBan", "logUnban", "logLeave", "logTimeout", "logAuditLog",
+}
+
+// An audit log entry of a moderator's action on the target. Handlers that wait for one find it right away.
+func auditLogEntry(action dg.AuditLogAction, targetID string, reason string) *dg.GuildAuditLogEntryCreate {
+	return &dg.GuildAuditLogEntryCreate{GuildID: guildID, AuditLogEntry: &dg.AuditLogEntry{
+		TargetID:   targetID,
+		UserID:     "400000000000000099",
+		ActionType: &action,
+		Options:    &dg.AuditLogOptions{ChannelID: channelID},
+		Reason:     reason,
+	}}
+}
+
+func TestLoggingEmbeds(t *testing.T) {
+	const logsChannelID = "300000000000000099"
+	config.LogsChannelID.Seed(guildID, json.Number(logsChannelID))
+	sentAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	until := time.Now().Add(time.Hour).Truncate(time.Second)
+
+	tests := []struct {
+		name  string
+		title string // empty if nothing should be logged
+		// the expected value of each named field; other fields aren't checked
+		fields map[string]string
+		events func(user *dg.User, message *dg.Message) []any
+	}{
+		{name: "message updated", title: "Message Updated", fields: map[string]string{
+			"Channel":          "<#" + channelID + ">",
+			"Author":           "<@400000000000000000>",
+			"Sent at":          fmt.Sprintf("<t:%d:f>", sentAt.Unix()),
+			"Previous Content": "first draft",
+			"New Content":      "second draft",
+		}, events: func(user *dg.User, message *dg.Message) []any {
+			edited := *message
+			edited.Content = "second draft"
+			edited.EditedTimestamp = lo.ToPtr(time.Now())
+			return []any{&dg.MessageCreate{Message: message}, &dg.MessageUpdate{Message: &edited}}
+		}},
+		{name: "uncached message updated", events: func(user *dg.User, message *dg.Message) []any {
+			message.EditedTimestamp = lo.ToPtr(time.Now())
+			return []any{&dg.MessageUpdate{Message: message}}
+		}},
+		{name: "message deleted", title: "Message Deleted", fields: map[string]string{
+			"Channel":      "<#" + channelID + ">",
+			"Author":       "<@400000000000000002>",
+			"Content":      "first draft",
+			"Attachments":  "https://cdn.example/a.png, https://cdn.example/b.png",
+			"Performed By": "<@400000000000000099>",
+			"Reason":       "spam",
+		}, events: func(user *dg.User, message *dg.Message) []any {
+			message.Attachments = []*dg.MessageAttachment{{URL: "https://cdn.example/a.png"}, {URL: "https://cdn.example/b.png"}}
+			return []any{
+				&dg.MessageCreate{Message: message},
+				auditLogEntry(dg.AuditLogActionMessageDelete, user.ID, "spam"),
+				&dg.MessageDelete{Message: &dg.Message{ID: message.ID, GuildID: guildID, ChannelID: channelID}},
+			}
+		}},
+		{name: "banned", title: "User Banned", fields: map[string]string{
+			"User ID":      "400000000000000003",
+			"Username":     "member3",
+			"Performed By": "<@400000000000000099>",
+			"Reason":       "Not provided",
+		}, events: func(user *dg.User, message *dg.Message) []any {
+			return []any{auditLogEntry(dg.AuditLogActionMemberBanAdd, user.ID, ""), &dg.GuildBanAdd{GuildID: guildID, User: user}}
+		}},
+		{name: "unbanned", title: "User Unbanned", fields: map[string]string{
+			"User ID": "400000000000000004",
+			"Reason":  "appealed",
+		}, events: func(user *dg.User, message *dg.Message) []any {
+			return []any{auditLogEntry(dg.AuditLogActionMemberBanRemove, user.ID, "appealed"), &dg.GuildBanRemove{GuildID: guildID, User: user}}
+		}},
+		{name: "kicked", title: "User Kicked", fields: map[string]string{
+			"User ID":      "400000000000000005",
+			"Performed By": "<@400000000000000099>",
+		}, events: func(user *dg.User, message *dg.Message) []any {
+			return []any{
+				auditLogEntry(dg.AuditLogActionMemberKick, user.ID, ""),
+				&dg.GuildMemberRemove{Member: &dg.Member{GuildID: guildID, User: user}},
+			}
+		}},
+		{name: "timed out", title: "User Timed Out", fields: map[string]string{
+			"User ID":  "400000000000000006",
+			"Nickname": "nick6",
+			"Until":    fmt.Sprintf("<t:%d:f>", until.Unix()),
+		}, events: func(user *dg.User, message *dg.Message) []any {
+			return []any{&dg.GuildMemberUpdate{
+				Member:       &dg.Member{GuildID: guildID, User: user, Nick: "nick6", CommunicationDisabledUntil: &until},
+				BeforeUpdate: &dg.Member{GuildID: guildID, User: user, Nick: "nick6"},
+			}}
+		}},
+		{name: "timeout removed", title: "User Timeout Removed", fields: map[string]string{
+			"User ID":  "400000000000000007",
+			"Nickname": "nick7",
+		}, events: func(user *dg.User, message *dg.Message) []any {
+			return []any{&dg.GuildMemberUpdate{
+				Member:       &dg.Member{GuildID: guildID, User: user, Nick: "nick7"},
+				BeforeUpdate: &dg.Member{GuildID: guildID, User: user, Nick: "nick7", CommunicationDisabledUntil: &until},
+			}}
+		}},
+		{name: "member updated without a timeout", events: func(user *dg.User, message *dg.Message) []any {
+			return []any{&dg.GuildMemberUpdate{
+				Member:       &dg.Member{GuildID: guildID, User: user, Nick: "renamed"},
+				BeforeUpdate: &dg.Member{GuildID: guildID, User: user},
+			}}
+		}},
+	}
+	for i, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			session := replay.NewSession()
+			session.AddGuild(&dg.Guild{ID: guildID, Name: "Replay"})
+			// users and messages of their own, since the message cache and audit log entries outlive a test
+			user := &dg.User{ID: "40000000000000000" + strconv.Itoa(i), Username: "member" + strconv.Itoa(i)}
+			message := &dg.Message{
+				ID:        "60000000000000000" + strconv.Itoa(i),
+				GuildID:   guildID,
+				ChannelID: channelID,
+				Author:    user,
+				Content:   "first draft",
+				Timestamp: sentAt,
+			}
+
+			for _, event := range test.events(user, message) {
+				session.Dispatch(event, logHandlers...)
+			}
+
+			calls := session.API.Calls("POST /channels/" + logsChannelID + "/messages")
+			if test.title == "" {
+				if len(calls) != 0 {
+					t.Fatalf("logged %d messages, want none", len(calls))
+				}
+				return
+			}
+			if len(calls) != 1 {
+				t.Fatalf("logged %d messages, want 1", len(calls))
+			}
+			var logged dg.MessageSend
+			if err := calls[0].Decode(&logged); err != nil {
+				t.Fatal(err)
+			}
+			if len(logged.Embeds) != 1 || logged.Embeds[0].Title != test.title {
+				t.Fatalf("logged %+v, want an embed titled %q", logged.Embeds, test.title)
+			}
+			fields := lo.SliceToMap(logged.Embeds[0].Fields, func(field *dg.MessageEmbedField) (string, string) {
+				return field.Name, field.Value
+			})
+			for name, want := range test.fields {
+				if got, ok := fields[name]; !ok || got != want {
+					t.Errorf("field %q is %q, want %q", name, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestLoggingWithoutChannel(t *testing.T) {
+	const otherGuildID = "200000000000000002"
+	session := replay.NewSession()
+	session.AddGuild(&dg.Guild{ID: otherGuildID, Name: "Unlogged"})
+	user := &dg.User{ID: "400000000000000010", Username: "member"}
+
+	session.Dispatch(&dg.GuildMemberRemove{Member: &dg.Member{GuildID: otherGuildID, User: user}}, logHandlers...)
+	session.Dispatch(&dg.GuildBanAdd{GuildID: otherGuildID, User: user}, logHandlers...)
+
+	if calls := session.API.Calls(); len(calls) != 0 {
+		t.Errorf("made %d calls, want none: %v", len(calls), calls)
+	}
+}
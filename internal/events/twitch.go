@@ -1,9 +1,11 @@
 package events
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"net/http"
+	"snoozybot/internal/background"
 	"snoozybot/internal/config"
 	"snoozybot/internal/i18n"
 	"snoozybot/internal/twitch"
@@ -86,20 +88,20 @@ func twitchStreamPresenceUpdate(d EventData[dg.PresenceUpdate]) error {
 				}
 				if channelID != "" {
 					twitchChannel := activity.URL[22:]
-					go func(twitchChannel string, channelID string, userID string) {
+					background.Go("twitchStreamNotification", func(ctx context.Context) {
 						d.Logger.Debug().Str("channel", twitchChannel).Msg("Getting stream info")
-						if stream, isNew, err := twitch.AttemptGetStream(twitchChannel); err != nil {
+						if stream, isNew, err := twitch.AttemptGetStream(ctx, twitchChannel); err != nil {
 							d.Logger.Error().Str("channel", twitchChannel).Err(err).Msg("Failed to get stream info")
 							return
 						} else if isNew {
 							content := i18n.TemplateString(lo.Must(template.New("twitch_live").Parse(templateText)), &i18n.Vars{"user": d.Event.User.Mention()})
-							embed := generateStreamNotificationEmbed(&stream, d.Logger)
-							d.Logger.Info().Str("user", d.Event.User.ID).Str("twitch", twitchChannel).Str("channel", channelID).Msg("Sending stream notification")
-							d.Session.ChannelMessageSendComplex(channelID, &dg.MessageSend{Content: content, Embed: embed})
+							embed := generateStreamNotificationEmbed(ctx, &stream, d.Logger)
+							d.Logger.Info().Str("user", d.Event.User.ID).Str("twitch", twitchChannel).Str("channel", string(channelID)).Msg("Sending stream notification")
+							d.Session.ChannelMessageSendComplex(string(channelID), &dg.MessageSend{Content: content, Embed: embed})
 						} else {
 							d.Logger.Debug().Str("channel", twitchChannel).Msg("Stream is not new, skipping notification")
 						}
-					}(twitchChannel, string(channelID), d.Event.User.ID)
+					})
 				}
 				return nil
 			}
@@ -113,13 +115,17 @@ func twitchStreamPresenceUpdate(d EventData[dg.PresenceUpdate]) error {
 	return nil
 }
 
-func generateStreamNotificationEmbed(stream *helix.Stream, logger *zerolog.Logger) *dg.MessageEmbed {
+func generateStreamNotificationEmbed(ctx context.Context, stream *helix.Stream, logger *zerolog.Logger) *dg.MessageEmbed {
 	// Wait for the stream thumbnail to be available
 	thumbnailURL := strings.Replace(stream.ThumbnailURL, "{width}x{height}", "1024x576", 1)
-	lo.AttemptWithDelay(20, 30*time.Second, func(index int, duration time.Duration) error {
+	background.Retry(ctx, 20, 30*time.Second, func(index int) error {
 		// errors if a redirect is encountered. Twitch returns a 302 to the generic image if one isnt available.
-		res, err := noRedirectClient.Head(thumbnailURL)
-		logger.Debug().Err(err).Str("url", thumbnailURL).Int("status", res.StatusCode).Msg("Attempted to get thumbnail.")
+		req, err := http.NewRequestWithContext(ctx, http.MethodHead, thumbnailURL, nil)
+		if err != nil {
+			return err
+		}
+		res, err := noRedirectClient.Do(req)
+		logger.Debug().Err(err).Str("url", thumbnailURL).Int("status", lo.TernaryF(res == nil, lo.Empty[int], func() int { return res.StatusCode })).Msg("Attempted to get thumbnail.")
 		return err
 	})
 	userProfileImage, _ := twitch.GetProfileImageURL(stream.UserLogin)
@@ -0,0 +1,62 @@
+package features
+
+import (
+	"slices"
+	"snoozybot/internal/config"
+	"strings"
+
+	"github.com/samber/lo"
+)
+
+// A group of commands that can be turned on or off per guild. A feature is enabled when all of its required config
+// keys are set in the guild and, if the guild sets features.enabled, it is in that list. Without features.enabled,
+// every feature whose config is set is enabled.
+type Feature struct {
+	Name     string
+	Requires []config.Key
+}
+
+var (
+	Bedtime       = &Feature{Name: "bedtime"}
+	Birthday      = &Feature{Name: "birthday", Requires: []config.Key{config.ProfileBirthdayChannel}}
+	Fun           = &Feature{Name: "fun"}
+	Quotes        = &Feature{Name: "quotes"}
+	Reminders     = &Feature{Name: "reminders"}
+	Report        = &Feature{Name: "report", Requires: []config.Key{config.ReportChannelId}}
+	RolesRegulars = &Feature{Name: "roles.regulars", Requires: []config.Key{config.RolesRegularsRoleID}}
+	RolesTemp     = &Feature{Name: "roles.temp", Requires: []config.Key{config.RolesTempRoleID, config.RolesTempDuration}}
+	Tags          = &Feature{Name: "tags"}
+)
+
+var All = []*Feature{Bedtime, Birthday, Fun, Quotes, Reminders, Report, RolesRegulars, RolesTemp, Tags}
+
+func (f *Feature) Enabled(guildID string) bool {
+	if !lo.EveryBy(f.Requires, func(key config.Key) bool { return key.IsSet(guildID) }) {
+		return false
+	}
+	if enabled, err := config.FeaturesEnabled.Get(guildID).Value(); err == nil {
+		return slices.Contains(enabled, f.Name)
+	}
+	return true
+}
+
+// Returns the features enabled in a guild
+func Enabled(guildID string) []*Feature {
+	return lo.Filter(All, func(f *Feature, _ int) bool { return f.Enabled(guildID) })
+}
+
+// Returns a string that changes whenever the set of features enabled in a guild changes
+func Fingerprint(guildID string) string {
+	return strings.Join(lo.Map(Enabled(guildID), func(f *Feature, _ int) string { return f.Name }), ",")
+}
+
+// Drops the cached config that decides which features are enabled in a guild, so the next check sees changes made
+// directly in the database
+func ForgetConfig(guildID string) {
+	config.FeaturesEnabled.Forget(guildID)
+	for _, f := range All {
+		for _, key := range f.Requires {
+			key.Forget(guildID)
+		}
+	}
+}
@@ -126,7 +126,11 @@ func Get(lang dg.Locale, key string, vars ...*Vars) string {
 }
 
 func GetFragments(lang dg.Locale, key string, vars ...*Vars) Vars {
-	frags := fragments[key][lang]
+	return SampleFragments(fragments[key][lang])
+}
+
+// Picks one of the values of each fragment
+func SampleFragments(frags map[string][]string) Vars {
 	res := make(Vars)
 	for k, v := range frags {
 		res[k] = lo.Sample(v)
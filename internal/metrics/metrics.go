@@ -0,0 +1,91 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const namespace = "snoozybot"
+
+const (
+	OutcomeOK    = "ok"
+	OutcomeError = "error"
+	OutcomePanic = "panic"
+)
+
+var (
+	// Interactions handled, per command path (e.g. quotes/find). The number of guilds per deployment is small, so the
+	// guild label is safe here.
+	Interactions = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "interactions_total",
+		Help:      "Interactions handled, by command, interaction type, outcome and guild.",
+	}, []string{"command", "type", "outcome", "guild"})
+	InteractionDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "interaction_duration_seconds",
+		Help:      "Time spent handling interactions, by command and interaction type.",
+		Buckets:   []float64{.05, .1, .25, .5, 1, 2, 3, 5, 10},
+	}, []string{"command", "type"})
+
+	// Gateway events handled, per handler name given to createEventHandler. Message events are frequent, so these
+	// are not labelled by guild.
+	Events = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "events_total",
+		Help:      "Gateway events handled, by handler and outcome.",
+	}, []string{"event", "outcome"})
+	EventDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "event_duration_seconds",
+		Help:      "Time spent handling gateway events, by handler.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"event"})
+
+	TaskRuns = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "task_runs_total",
+		Help:      "Periodic task runs, by task and outcome.",
+	}, []string{"task", "outcome"})
+	// Task errors that do not concern a single guild. They are only logged, since they would otherwise leak one guild's
+	// details into the error channels of the others.
+	TaskErrorsUnreported = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "task_errors_unreported_total",
+		Help:      "Periodic task errors not reported to any guild, by task.",
+	}, []string{"task"})
+	TaskDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "task_duration_seconds",
+		Help:      "Duration of periodic task runs, by task.",
+		Buckets:   []float64{.1, .5, 1, 5, 10, 30, 60, 120, 300},
+	}, []string{"task"})
+
+	// Calls to external services: openai, twitch, youtube and bluesky
+	APIRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "api_requests_total",
+		Help:      "Outbound API calls, by service, operation and outcome.",
+	}, []string{"service", "operation", "outcome"})
+	APIDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "api_request_duration_seconds",
+		Help:      "Latency of outbound API calls, by service and operation.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"service", "operation"})
+)
+
+func Outcome(err error) string {
+	if err != nil {
+		return OutcomeError
+	}
+	return OutcomeOK
+}
+
+// Records the latency and outcome of an outbound API call that started at start.
+func ObserveAPI(service string, operation string, start time.Time, err error) {
+	APIDuration.WithLabelValues(service, operation).Observe(time.Since(start).Seconds())
+	APIRequests.WithLabelValues(service, operation, Outcome(err)).Inc()
+}
@@ -0,0 +1,168 @@
+package replay
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+
+	dg "github.com/bwmarrin/discordgo"
+	"github.com/rs/zerolog/log"
+)
+
+var apiPrefix = "/api/v" + dg.APIVersion
+
+// A REST call made by a handler
+type Call struct {
+	Method      string
+	Path        string // without the /api/vN prefix, e.g. /channels/123/messages
+	ContentType string
+	Body        []byte
+}
+
+// Decodes the JSON body of the call. For multipart calls (messages with files) this is the payload_json part.
+func (c Call) Decode(v any) error {
+	mediaType, params, _ := mime.ParseMediaType(c.ContentType)
+	if mediaType != "multipart/form-data" {
+		return json.Unmarshal(c.Body, v)
+	}
+	reader := multipart.NewReader(bytes.NewReader(c.Body), params["boundary"])
+	for {
+		part, err := reader.NextPart()
+		if err != nil {
+			return fmt.Errorf("payload_json not found in multipart body: %w", err)
+		}
+		if part.FormName() == "payload_json" {
+			return json.NewDecoder(part).Decode(v)
+		}
+	}
+}
+
+func (c Call) String() string {
+	return c.Method + " " + c.Path
+}
+
+// A fake discord REST API. Every request is recorded. Requests matching a stub get the stubbed response; GETs of
+// guilds, channels, members and roles are answered from the session state; anything else gets its request body back
+// (so a sent message decodes as itself) or an empty object.
+type FakeAPI struct {
+	State *dg.State
+
+	mu    sync.Mutex
+	calls []Call
+	stubs *http.ServeMux
+}
+
+func NewFakeAPI(state *dg.State) *FakeAPI {
+	api := &FakeAPI{State: state, stubs: http.NewServeMux()}
+	api.stubs.HandleFunc("GET /guilds/{guild}", func(w http.ResponseWriter, r *http.Request) {
+		api.serveState(w, func() (any, error) { return state.Guild(r.PathValue("guild")) })
+	})
+	api.stubs.HandleFunc("GET /guilds/{guild}/members/{user}", func(w http.ResponseWriter, r *http.Request) {
+		api.serveState(w, func() (any, error) { return state.Member(r.PathValue("guild"), r.PathValue("user")) })
+	})
+	api.stubs.HandleFunc("GET /guilds/{guild}/roles", func(w http.ResponseWriter, r *http.Request) {
+		api.serveState(w, func() (any, error) {
+			guild, err := state.Guild(r.PathValue("guild"))
+			if err != nil {
+				return nil, err
+			}
+			return guild.Roles, nil
+		})
+	})
+	api.stubs.HandleFunc("GET /channels/{channel}", func(w http.ResponseWriter, r *http.Request) {
+		api.serveState(w, func() (any, error) { return state.Channel(r.PathValue("channel")) })
+	})
+	api.stubs.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if len(body) == 0 || !json.Valid(body) {
+			body = []byte("{}")
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(body)
+	})
+	return api
+}
+
+// Responds to requests matching pattern (a http.ServeMux pattern without the /api/vN prefix, e.g.
+// "GET /guilds/{guild}/audit-logs") with the given status and JSON encoded body. Later stubs for the same pattern
+// replace earlier ones.
+func (api *FakeAPI) Stub(pattern string, status int, body any) {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	raw, err := json.Marshal(body)
+	if err != nil {
+		log.Panic().Err(err).Str("pattern", pattern).Msg("Cannot encode stub response")
+	}
+	// ServeMux panics on duplicate patterns, so the mux is rebuilt on top of a fresh one each time
+	previous := api.stubs
+	api.stubs = http.NewServeMux()
+	api.stubs.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write(raw)
+	})
+	api.stubs.Handle("/", previous)
+}
+
+// Returns the calls made so far, optionally only those whose "METHOD /path" starts with prefix
+func (api *FakeAPI) Calls(prefix ...string) []Call {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	var calls []Call
+	for _, call := range api.calls {
+		if len(prefix) == 0 || strings.HasPrefix(call.String(), prefix[0]) {
+			calls = append(calls, call)
+		}
+	}
+	return calls
+}
+
+// Forgets recorded calls. Stubs are kept.
+func (api *FakeAPI) Reset() {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	api.calls = nil
+}
+
+// Implements http.RoundTripper, so the API can be used as the transport of a session's HTTP client.
+func (api *FakeAPI) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		body, _ = io.ReadAll(req.Body)
+		req.Body.Close()
+	}
+	path := strings.TrimPrefix(req.URL.Path, apiPrefix)
+
+	api.mu.Lock()
+	api.calls = append(api.calls, Call{Method: req.Method, Path: path, ContentType: req.Header.Get("Content-Type"), Body: body})
+	stubs := api.stubs
+	api.mu.Unlock()
+
+	routed := req.Clone(req.Context())
+	routed.URL.Path = path
+	routed.URL.RawPath = ""
+	routed.Body = io.NopCloser(bytes.NewReader(body))
+	recorder := httptest.NewRecorder()
+	stubs.ServeHTTP(recorder, routed)
+	response := recorder.Result()
+	response.Request = req
+	return response, nil
+}
+
+func (api *FakeAPI) serveState(w http.ResponseWriter, get func() (any, error)) {
+	w.Header().Set("Content-Type", "application/json")
+	value, err := get()
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]any{"message": err.Error(), "code": 0})
+		return
+	}
+	json.NewEncoder(w).Encode(value)
+}
@@ -0,0 +1,128 @@
+package replay
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"slices"
+	"snoozybot/internal/bot"
+	"snoozybot/internal/commands"
+	"snoozybot/internal/events"
+
+	dg "github.com/bwmarrin/discordgo"
+	"github.com/rs/zerolog/log"
+)
+
+// A gateway dispatch payload, as sent by discord or captured from a running bot
+type Payload struct {
+	Type string          `json:"t"`
+	Data json.RawMessage `json:"d"`
+}
+
+// Gateway event types the bot's handlers listen to
+var eventTypes = map[string]func() any{
+	"GUILD_CREATE":                 func() any { return &dg.GuildCreate{} },
+	"GUILD_MEMBER_UPDATE":          func() any { return &dg.GuildMemberUpdate{} },
+	"GUILD_MEMBER_REMOVE":          func() any { return &dg.GuildMemberRemove{} },
+	"GUILD_BAN_ADD":                func() any { return &dg.GuildBanAdd{} },
+	"GUILD_BAN_REMOVE":             func() any { return &dg.GuildBanRemove{} },
+	"GUILD_AUDIT_LOG_ENTRY_CREATE": func() any { return &dg.GuildAuditLogEntryCreate{} },
+	"MESSAGE_CREATE":               func() any { return &dg.MessageCreate{} },
+	"MESSAGE_UPDATE":               func() any { return &dg.MessageUpdate{} },
+	"MESSAGE_DELETE":               func() any { return &dg.MessageDelete{} },
+	"PRESENCE_UPDATE":              func() any { return &dg.PresenceUpdate{} },
+	"INTERACTION_CREATE":           func() any { return &dg.InteractionCreate{} },
+}
+
+// Decodes a payload into its event struct, e.g. *dg.MessageCreate for MESSAGE_CREATE
+func (p Payload) Event() (any, error) {
+	create, ok := eventTypes[p.Type]
+	if !ok {
+		return nil, fmt.Errorf("unsupported event type %s", p.Type)
+	}
+	event := create()
+	if err := json.Unmarshal(p.Data, event); err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %w", p.Type, err)
+	}
+	return event, nil
+}
+
+// Reads payloads from a JSON file containing a single payload or a list of them
+func LoadPayloads(path string) ([]Payload, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var payloads []Payload
+	if err := json.Unmarshal(raw, &payloads); err != nil {
+		var payload Payload
+		if err := json.Unmarshal(raw, &payload); err != nil {
+			return nil, fmt.Errorf("%s is neither a payload nor a list of payloads: %w", path, err)
+		}
+		payloads = []Payload{payload}
+	}
+	return payloads, nil
+}
+
+// Feeds payloads to the bot in order, as if they were received from the gateway
+func (s *Session) Replay(payloads ...Payload) error {
+	for _, payload := range payloads {
+		event, err := payload.Event()
+		if err != nil {
+			return err
+		}
+		if interaction, ok := event.(*dg.InteractionCreate); ok {
+			s.Interact(interaction)
+		} else {
+			s.Dispatch(event)
+		}
+	}
+	return nil
+}
+
+// Calls every handler in events.Events that accepts the event, or only the named handlers if any are given.
+// Returns the number of handlers called.
+func (s *Session) Dispatch(event any, handlers ...string) int {
+	called := 0
+	eventType := reflect.TypeOf(event)
+	for _, handler := range events.Events {
+		if len(handlers) > 0 && !slices.Contains(handlers, handler.Name) {
+			continue
+		}
+		fn := reflect.ValueOf(handler.Handler)
+		if fn.Type().NumIn() != 2 || fn.Type().In(1) != eventType {
+			continue
+		}
+		fn.Call([]reflect.Value{reflect.ValueOf(s.Session), reflect.ValueOf(event)})
+		called++
+	}
+	if called == 0 {
+		log.Warn().Str("type", eventType.String()).Strs("handlers", handlers).Msg("No handler accepts this event")
+	}
+	return called
+}
+
+// Sends an interaction through the command tree, as the bot does for interactions from the gateway.
+// Interaction responses are recorded by the fake API as POST /interactions/{id}/{token}/callback.
+func (s *Session) Interact(interaction *dg.InteractionCreate) {
+	bot.HandleInteraction(s.Session, interaction, s.Manager, log.Logger)
+}
+
+// Records the bot lifecycle operations that commands trigger, without doing anything
+type Manager struct {
+	Reloads       int
+	Registrations []string // guild IDs
+}
+
+func (m *Manager) Reload() error {
+	m.Reloads++
+	return nil
+}
+
+func (m *Manager) RegisterCommands(guildID string, force bool) error {
+	m.Registrations = append(m.Registrations, guildID)
+	return nil
+}
+
+var _ commands.Manager = (*Manager)(nil)
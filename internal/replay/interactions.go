@@ -0,0 +1,107 @@
+package replay
+
+import (
+	"fmt"
+	"sync/atomic"
+
+	dg "github.com/bwmarrin/discordgo"
+	"github.com/samber/lo"
+)
+
+var nextID atomic.Uint64
+
+// Creates a slash command interaction from a member in a guild channel. The interaction and guild locales are en-US.
+// Options of subcommands are nested, e.g. Subcommand("find", Option("text_search", "fox")).
+func CommandInteraction(guildID string, channelID string, member *dg.Member, name string, options ...*dg.ApplicationCommandInteractionDataOption) *dg.InteractionCreate {
+	guildLocale := dg.EnglishUS
+	interactionID := fmt.Sprint(nextID.Add(1))
+	return &dg.InteractionCreate{Interaction: &dg.Interaction{
+		ID:          interactionID,
+		AppID:       BotUser.ID,
+		Type:        dg.InteractionApplicationCommand,
+		GuildID:     guildID,
+		ChannelID:   channelID,
+		Member:      member,
+		Locale:      dg.EnglishUS,
+		GuildLocale: &guildLocale,
+		Token:       "token-" + interactionID,
+		Data: dg.ApplicationCommandInteractionData{
+			ID:          "command-" + name,
+			Name:        name,
+			CommandType: dg.ChatApplicationCommand,
+			Options:     options,
+		},
+	}}
+}
+
+// A user command (context menu) interaction targeting a user
+func UserCommandInteraction(guildID string, channelID string, member *dg.Member, name string, target *dg.User) *dg.InteractionCreate {
+	interaction := CommandInteraction(guildID, channelID, member, name)
+	data := interaction.ApplicationCommandData()
+	data.CommandType = dg.UserApplicationCommand
+	data.TargetID = target.ID
+	data.Resolved = &dg.ApplicationCommandInteractionDataResolved{Users: map[string]*dg.User{target.ID: target}}
+	interaction.Data = data
+	return interaction
+}
+
+func Subcommand(name string, options ...*dg.ApplicationCommandInteractionDataOption) *dg.ApplicationCommandInteractionDataOption {
+	return &dg.ApplicationCommandInteractionDataOption{Name: name, Type: dg.ApplicationCommandOptionSubCommand, Options: options}
+}
+
+func SubcommandGroup(name string, subcommands ...*dg.ApplicationCommandInteractionDataOption) *dg.ApplicationCommandInteractionDataOption {
+	return &dg.ApplicationCommandInteractionDataOption{Name: name, Type: dg.ApplicationCommandOptionSubCommandGroup, Options: subcommands}
+}
+
+// An option value. Users, channels and roles are passed by ID with the matching option type, e.g.
+// TypedOption("user", dg.ApplicationCommandOptionUser, "123").
+func Option(name string, value any) *dg.ApplicationCommandInteractionDataOption {
+	var optionType dg.ApplicationCommandOptionType
+	switch value.(type) {
+	case string:
+		optionType = dg.ApplicationCommandOptionString
+	case bool:
+		optionType = dg.ApplicationCommandOptionBoolean
+	case int, int64:
+		optionType = dg.ApplicationCommandOptionInteger
+	case float64:
+		optionType = dg.ApplicationCommandOptionNumber
+	}
+	return TypedOption(name, optionType, value)
+}
+
+func TypedOption(name string, optionType dg.ApplicationCommandOptionType, value any) *dg.ApplicationCommandInteractionDataOption {
+	// numbers are decoded from JSON as float64
+	switch number := value.(type) {
+	case int:
+		value = float64(number)
+	case int64:
+		value = float64(number)
+	}
+	return &dg.ApplicationCommandInteractionDataOption{Name: name, Type: optionType, Value: value}
+}
+
+// A button click, or a select menu choice if values are given, on a message sent by the bot
+func ComponentInteraction(guildID string, channelID string, member *dg.Member, message *dg.Message, customID string, values ...string) *dg.InteractionCreate {
+	interaction := CommandInteraction(guildID, channelID, member, "")
+	interaction.Type = dg.InteractionMessageComponent
+	interaction.Message = message
+	interaction.Data = dg.MessageComponentInteractionData{
+		CustomID:      customID,
+		ComponentType: lo.Ternary(len(values) > 0, dg.SelectMenuComponent, dg.ButtonComponent),
+		Values:        values,
+	}
+	return interaction
+}
+
+// A modal submit with the given text input values by custom ID
+func ModalSubmitInteraction(guildID string, channelID string, member *dg.Member, customID string, inputs map[string]string) *dg.InteractionCreate {
+	interaction := CommandInteraction(guildID, channelID, member, "")
+	interaction.Type = dg.InteractionModalSubmit
+	var rows []dg.MessageComponent
+	for inputID, value := range inputs {
+		rows = append(rows, &dg.ActionsRow{Components: []dg.MessageComponent{&dg.TextInput{CustomID: inputID, Value: value}}})
+	}
+	interaction.Data = dg.ModalSubmitInteractionData{CustomID: customID, Components: rows}
+	return interaction
+}
@@ -0,0 +1,68 @@
+package replay
+
+import (
+	"net/http"
+
+	dg "github.com/bwmarrin/discordgo"
+	"github.com/rs/zerolog/log"
+)
+
+// The bot user of replay sessions
+var BotUser = &dg.User{ID: "100000000000000001", Username: "snoozybot", Bot: true}
+
+// A session that never connects to the gateway and sends every REST call to a fake API. Guilds, channels, members
+// and roles added to the state are also served by the fake API.
+type Session struct {
+	*dg.Session
+	API     *FakeAPI
+	Manager *Manager
+}
+
+func NewSession() *Session {
+	session, err := dg.New("Bot replay")
+	if err != nil {
+		log.Panic().Err(err).Msg("Failed to create replay session")
+	}
+	session.State.User = BotUser
+	session.State.TrackMembers = true
+	session.State.TrackRoles = true
+	session.State.TrackChannels = true
+	api := NewFakeAPI(session.State)
+	session.Client = &http.Client{Transport: api}
+	session.MaxRestRetries = 0
+	return &Session{Session: session, API: api, Manager: &Manager{}}
+}
+
+// Adds a guild with its channels, roles and members to the state. The guild's preferred locale defaults to en-US.
+func (s *Session) AddGuild(guild *dg.Guild) *dg.Guild {
+	if guild.PreferredLocale == "" {
+		guild.PreferredLocale = string(dg.EnglishUS)
+	}
+	for _, member := range guild.Members {
+		member.GuildID = guild.ID
+	}
+	for _, channel := range guild.Channels {
+		channel.GuildID = guild.ID
+	}
+	if err := s.State.GuildAdd(guild); err != nil {
+		log.Panic().Err(err).Str("guild", guild.ID).Msg("Failed to add guild to replay state")
+	}
+	return guild
+}
+
+// Adds a member to a guild that was added with AddGuild
+func (s *Session) AddMember(guildID string, member *dg.Member) *dg.Member {
+	member.GuildID = guildID
+	if err := s.State.MemberAdd(member); err != nil {
+		log.Panic().Err(err).Str("guild", guildID).Msg("Failed to add member to replay state")
+	}
+	return member
+}
+
+// Adds a channel to a guild that was added with AddGuild
+func (s *Session) AddChannel(channel *dg.Channel) *dg.Channel {
+	if err := s.State.ChannelAdd(channel); err != nil {
+		log.Panic().Err(err).Str("guild", channel.GuildID).Msg("Failed to add channel to replay state")
+	}
+	return channel
+}
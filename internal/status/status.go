@@ -0,0 +1,123 @@
+package status
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"snoozybot/internal/database"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/rs/zerolog/log"
+)
+
+// Gateway state of a single bot session (shard)
+type Session struct {
+	Bot             string   `json:"bot"`
+	Shard           int      `json:"shard"`
+	ShardCount      int      `json:"shardCount"`
+	Ready           bool     `json:"ready"`
+	LatencyMs       int64    `json:"heartbeatLatencyMs"`
+	Guilds          []string `json:"guilds"`          // guilds configured for this shard
+	ConnectedGuilds []string `json:"connectedGuilds"` // guilds the shard has received from the gateway
+}
+
+// Run history of a periodic task
+type Task struct {
+	Name       string     `json:"name"`
+	Runs       uint       `json:"runs"`
+	LastRun    *time.Time `json:"lastRun,omitempty"`
+	DurationMs int64      `json:"lastDurationMs"`
+	LastError  string     `json:"lastError,omitempty"`
+}
+
+type Database struct {
+	Connected bool   `json:"connected"`
+	Error     string `json:"error,omitempty"`
+}
+
+type Report struct {
+	Ready    bool      `json:"ready"`
+	Sessions []Session `json:"sessions"`
+	Tasks    []Task    `json:"tasks"`
+	Database Database  `json:"database"`
+}
+
+// Sources of status information. Implemented by the bot and task managers.
+type SessionReporter interface {
+	Started() bool
+	SessionStatus() []Session
+}
+
+type TaskReporter interface {
+	AllTasksRan() bool
+	TaskStatus() []Task
+}
+
+type Server struct {
+	Bots  SessionReporter
+	Tasks TaskReporter
+}
+
+// Starts serving the status endpoints on addr in the background:
+//   - /healthz always returns 200 while the process is up
+//   - /readyz returns 200 once all bots have started, every task has run once and the database is reachable
+//   - /status returns the full report as JSON
+//   - /metrics exposes prometheus metrics
+func (s *Server) Serve(addr string) *http.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		report := s.Report(r.Context())
+		if !report.Ready {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte("not ready"))
+			return
+		}
+		w.Write([]byte("ok"))
+	})
+	mux.HandleFunc("/status", func(w http.ResponseWriter, r *http.Request) {
+		report := s.Report(r.Context())
+		w.Header().Set("Content-Type", "application/json")
+		if !report.Ready {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		json.NewEncoder(w).Encode(report)
+	})
+	mux.Handle("/metrics", promhttp.Handler())
+
+	server := &http.Server{Addr: addr, Handler: mux, ReadHeaderTimeout: 5 * time.Second}
+	go func() {
+		log.Info().Str("addr", addr).Msg("Starting status server")
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Error().Err(err).Msg("Status server stopped unexpectedly")
+		}
+	}()
+	return server
+}
+
+func (s *Server) Report(ctx context.Context) Report {
+	db := pingDatabase(ctx)
+	return Report{
+		Ready:    s.Bots.Started() && s.Tasks.AllTasksRan() && db.Connected,
+		Sessions: s.Bots.SessionStatus(),
+		Tasks:    s.Tasks.TaskStatus(),
+		Database: db,
+	}
+}
+
+func pingDatabase(ctx context.Context) Database {
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+	sqlDB, err := database.Database.DB()
+	if err == nil {
+		err = sqlDB.PingContext(ctx)
+	}
+	if err != nil {
+		return Database{Connected: false, Error: err.Error()}
+	}
+	return Database{Connected: true}
+}
@@ -2,15 +2,20 @@ package tasks
 
 import (
 	"context"
+	"runtime/debug"
 	"snoozybot/internal/bot"
+	"snoozybot/internal/errorreport"
 	"time"
 
+	dg "github.com/bwmarrin/discordgo"
+
 	"github.com/rs/zerolog"
 )
 
 type PeriodicTask struct {
 	Name        string
 	Interval    time.Duration
+	Intents     dg.Intent // gateway intents the task relies on, in addition to those of the event handlers
 	TaskHandler func(ctx *TaskData) error
 }
 
@@ -18,4 +23,20 @@ type TaskData struct {
 	BotManager *bot.BotManager
 	Logger     zerolog.Logger
 	Context    context.Context
+	// Reports an error the task went on after, e.g. a notification that failed in one guild while the others were
+	// sent. Errors marked with errorreport.ForGuild are reported to that guild.
+	ReportError func(err error, stack []byte)
+}
+
+// Runs the part of a task that concerns one guild. Its error or panic is reported to that guild, and the task goes on
+// with the other guilds.
+func (td *TaskData) ForGuild(guildID string, fn func() error) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			td.ReportError(errorreport.ForGuild(guildID, errorreport.PanicError(rec)), debug.Stack())
+		}
+	}()
+	if err := fn(); err != nil {
+		td.ReportError(errorreport.ForGuild(guildID, err), nil)
+	}
 }
@@ -3,10 +3,12 @@ package tasks
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"snoozybot/internal/config"
 	"snoozybot/internal/i18n"
+	"snoozybot/internal/metrics"
 	"strings"
 	"text/template"
 	"time"
@@ -14,23 +16,30 @@ import (
 	"github.com/bluesky-social/indigo/api/atproto"
 	"github.com/bluesky-social/indigo/api/bsky"
 	"github.com/bluesky-social/indigo/xrpc"
+	dg "github.com/bwmarrin/discordgo"
 	"github.com/samber/lo"
 )
 
 const BSKY_HOST = "https://bsky.social"
 
+// Logged in on the first run of the task, so the package can be loaded without Bluesky credentials
 var bskyClient *xrpc.Client
 var userLastKnownPosts = make(map[string]time.Time)
 
-func init() {
-	username := lo.Must(os.LookupEnv("BSKY_USERNAME"))
-	appPassword := lo.Must(os.LookupEnv("BSKY_APP_PASSWORD"))
-
-	sess := lo.Must(atproto.ServerCreateSession(context.Background(), &xrpc.Client{Host: BSKY_HOST}, &atproto.ServerCreateSession_Input{
+func bskyCreateSession(ctx context.Context) error {
+	username, ok := os.LookupEnv("BSKY_USERNAME")
+	if !ok {
+		return errors.New("BSKY_USERNAME is not set")
+	}
+	start := time.Now()
+	sess, err := atproto.ServerCreateSession(ctx, &xrpc.Client{Host: BSKY_HOST}, &atproto.ServerCreateSession_Input{
 		Identifier: username,
-		Password:   appPassword,
-	}))
-
+		Password:   os.Getenv("BSKY_APP_PASSWORD"),
+	})
+	metrics.ObserveAPI("bluesky", "com.atproto.server.createSession", start, err)
+	if err != nil {
+		return err
+	}
 	bskyClient = &xrpc.Client{
 		Host: BSKY_HOST,
 		Auth: &xrpc.AuthInfo{
@@ -39,9 +48,14 @@ func init() {
 			RefreshJwt: sess.RefreshJwt,
 		},
 	}
+	return nil
 }
 
 func bskyRefreshSession(tctx *TaskData) error {
+	if bskyClient == nil {
+		return bskyCreateSession(tctx.Context)
+	}
+	start := time.Now()
 	resp, err := atproto.ServerRefreshSession(tctx.Context, &xrpc.Client{
 		Host: BSKY_HOST,
 		Auth: &xrpc.AuthInfo{
@@ -50,6 +64,7 @@ func bskyRefreshSession(tctx *TaskData) error {
 			RefreshJwt: bskyClient.Auth.RefreshJwt,
 		},
 	})
+	metrics.ObserveAPI("bluesky", "com.atproto.server.refreshSession", start, err)
 	if err != nil {
 		return err
 	}
@@ -61,6 +76,7 @@ func bskyRefreshSession(tctx *TaskData) error {
 var bskyNotificationTask = PeriodicTask{
 	Name:     "bskyNotificationTask",
 	Interval: 1 * time.Minute,
+	Intents:  dg.IntentsNone, // only uses the REST API
 	TaskHandler: func(tctx *TaskData) error {
 		if err := bskyRefreshSession(tctx); err != nil {
 			return err
@@ -73,19 +89,23 @@ var bskyNotificationTask = PeriodicTask{
 		// This way each user is only checked once even if they're in multiple guilds
 		userGuilds := make(map[string][]string)
 		for guildId, users := range guildUsers {
-			users, err := users.Value()
-			if err != nil {
-				tctx.Logger.Error().Err(err).Str("guild_id", guildId).Msg("Failed to parse bluesky user IDs.")
-				continue
-			}
-			for _, user := range users {
-				userGuilds[user] = append(userGuilds[user], guildId)
-			}
+			tctx.ForGuild(guildId, func() error {
+				users, err := users.Value()
+				if err != nil {
+					return fmt.Errorf("failed to parse bluesky user IDs: %w", err)
+				}
+				for _, user := range users {
+					userGuilds[user] = append(userGuilds[user], guildId)
+				}
+				return nil
+			})
 		}
 
 		for user, guildIds := range userGuilds {
 			// Get user's last 5 posts
+			start := time.Now()
 			posts, err := bsky.FeedGetAuthorFeed(tctx.Context, bskyClient, user, "", "posts_no_replies", false, 5)
+			metrics.ObserveAPI("bluesky", "app.bsky.feed.getAuthorFeed", start, err)
 			if err != nil {
 				tctx.Logger.Error().Err(err).Str("user", user).Msg("Failed to get user's latest posts.")
 				continue
@@ -126,7 +146,9 @@ var bskyNotificationTask = PeriodicTask{
 						continue
 					}
 					for _, guildId := range guildIds {
-						_sendBskyNotification(tctx, user, guildId, channels[guildId], templates[guildId], post)
+						tctx.ForGuild(guildId, func() error {
+							return _sendBskyNotification(tctx, user, guildId, channels[guildId], templates[guildId], post)
+						})
 					}
 				}
 			}
@@ -135,36 +157,31 @@ var bskyNotificationTask = PeriodicTask{
 	},
 }
 
-func _sendBskyNotification(tctx *TaskData, user string, guildId string, channel *config.ConfigValue[json.Number], tmplConfig *config.ConfigValue[string], post *bsky.FeedDefs_FeedViewPost) {
+func _sendBskyNotification(tctx *TaskData, user string, guildId string, channel *config.ConfigValue[json.Number], tmplConfig *config.ConfigValue[string], post *bsky.FeedDefs_FeedViewPost) error {
 	channelId, err := channel.Value()
 	if err != nil {
-		tctx.Logger.Error().Err(err).Str("guild_id", guildId).Msg("Failed to parse channel id.")
-		return
+		return fmt.Errorf("failed to parse channel id: %w", err)
 	}
 	tmplStr, err := tmplConfig.Value()
 	if err != nil {
-		tctx.Logger.Error().Err(err).Str("guild_id", guildId).Msg("Failed to parse template.")
-		return
+		return fmt.Errorf("failed to parse template: %w", err)
 	}
 	tmpl, err := template.New("bsky_notif").Parse(tmplStr)
 	if err != nil {
-		tctx.Logger.Error().Err(err).Str("guild_id", guildId).Msg("Failed to create template for bsky notification.")
-		return
+		return fmt.Errorf("failed to create template for bsky notification: %w", err)
 	}
-	bot, ok := tctx.BotManager.GuildBots[guildId]
+	bot, ok := tctx.BotManager.GetBot(guildId)
 	if !ok {
-		tctx.Logger.Error().Str("guild_id", guildId).Msg("No bot found for guild.")
-		return
+		return fmt.Errorf("no bot for guild %s", guildId)
 	}
 	postId, ok := lo.Last(strings.Split(post.Post.Uri, "/"))
 	if !ok {
-		tctx.Logger.Error().Str("user", user).Str("post_uri", post.Post.Uri).Msg("Failed to parse post ID.")
-		return
+		return fmt.Errorf("failed to parse post ID of %s", post.Post.Uri)
 	}
 	content := i18n.TemplateString(tmpl, &i18n.Vars{"url": fmt.Sprintf("https://bsky.app/profile/%s/post/%s", post.Post.Author.Handle, postId)})
 	if _, err := bot.ChannelMessageSend(string(channelId), content); err != nil {
-		tctx.Logger.Error().Err(err).Str("guild_id", guildId).Str("user", user).Msg("Failed to send bluesky notification.")
-	} else {
-		tctx.Logger.Info().Str("guild_id", guildId).Str("user", user).Str("content", content).Msg("Found new bluesky post, sent notification.")
+		return fmt.Errorf("failed to send bluesky notification for %s: %w", user, err)
 	}
+	tctx.Logger.Info().Str("guild_id", guildId).Str("user", user).Str("content", content).Msg("Found new bluesky post, sent notification.")
+	return nil
 }
@@ -1,7 +1,10 @@
 package tasks
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
+	"snoozybot/internal/background"
 	"snoozybot/internal/config"
 	"snoozybot/internal/database"
 	"snoozybot/internal/i18n"
@@ -15,83 +18,110 @@ import (
 var storedScheduledTask = PeriodicTask{
 	Name:     "storedScheduledTask",
 	Interval: 1 * time.Minute,
+	Intents:  dg.IntentsNone, // only uses the REST API
 	TaskHandler: func(ctx *TaskData) error {
 		var dueTasks []database.ScheduledTask
 		// Get all tasks that are due to be processed and delete at the same time to prevent duplicate processing
 		database.Database.Clauses(clause.Returning{}).Where("process_after < ?", time.Now()).Delete(&dueTasks)
 
-		// Start gorountines for each task
+		// Start gorountines for each task. Their errors are reported to the guild of the task.
 		for _, task := range dueTasks {
 			ctx.Logger = ctx.Logger.With().Uint("task", task.ID).Uint("type", uint(task.TaskType)).Logger()
+			var name string
+			var process func(*database.ScheduledTask, *TaskData) error
 			switch task.TaskType {
 			case database.TaskTypeReminder:
-				go processReminder(&task, ctx)
+				name, process = "processReminder", processReminder
 			case database.TaskTypeBirthday:
-				go processBirthday(&task, ctx)
+				name, process = "processBirthday", processBirthday
 			case database.TaskTypeRemoveRole:
-				go processRemoveRole(&task, ctx)
+				name, process = "processRemoveRole", processRemoveRole
 			default:
 				ctx.Logger.Warn().Uint("type", uint(task.TaskType)).Msg("Unknown task type")
+				continue
 			}
+			background.Go(name, func(context.Context) {
+				ctx.ForGuild(task.GuildID, func() error { return process(&task, ctx) })
+			})
 		}
 		return nil
 	},
 }
 
-func _getTaskInfo(task *database.ScheduledTask, ctx *TaskData) (*dg.Session, *dg.Guild, *dg.Member) {
-	bot, ok := ctx.BotManager.GuildBots[task.GuildID]
+func _getTaskInfo(task *database.ScheduledTask, ctx *TaskData) (*dg.Session, *dg.Guild, *dg.Member, error) {
+	bot, ok := ctx.BotManager.GetBot(task.GuildID)
 	if !ok {
-		ctx.Logger.Warn().Str("guild", task.GuildID).Msg("Bot not found for guild")
-		return nil, nil, nil
+		return nil, nil, nil, fmt.Errorf("no bot for guild %s", task.GuildID)
 	}
 	guild, err := bot.Guild(task.GuildID)
 	if err != nil {
-		ctx.Logger.Error().Err(err).Msg("Failed to get guild")
-		return nil, nil, nil
+		return nil, nil, nil, fmt.Errorf("failed to get guild: %w", err)
 	}
 	member, err := bot.GuildMember(task.GuildID, task.UserID)
 	if err != nil {
-		ctx.Logger.Error().Err(err).Msg("Failed to get guild member")
-		return nil, nil, nil
+		return nil, nil, nil, fmt.Errorf("failed to get guild member %s: %w", task.UserID, err)
 	}
-	return bot, guild, member
+	return bot, guild, member, nil
 }
 
 func processReminder(task *database.ScheduledTask, ctx *TaskData) error {
-	bot, guild, member := _getTaskInfo(task, ctx)
-	if bot == nil || guild == nil || member == nil {
-		return nil
+	if task.GuildID == "" {
+		return processDMReminder(task, ctx)
+	}
+	bot, guild, member, err := _getTaskInfo(task, ctx)
+	if err != nil {
+		return err
 	}
 	var payload database.ScheduledTaskReminderPayload
 	if err := json.Unmarshal(task.Payload, &payload); err != nil {
-		ctx.Logger.Error().Err(err).Msg("Failed to parse scheduled task payload")
-		return nil
+		return fmt.Errorf("failed to parse scheduled task payload: %w", err)
 	}
 	text := i18n.Get(dg.Locale(guild.PreferredLocale), "reminder/notif", &i18n.Vars{"name": member.Mention(), "content": payload.Reason})
 	if _, err := bot.ChannelMessageSendComplex(payload.ChannelID, &dg.MessageSend{
 		Content:         text,
 		AllowedMentions: &dg.MessageAllowedMentions{Parse: []dg.AllowedMentionType{dg.AllowedMentionTypeUsers}},
 	}); err != nil {
-		ctx.Logger.Error().Err(err).Msg("Failed to send reminder message")
-		return err
+		return fmt.Errorf("failed to send reminder message: %w", err)
+	}
+	return nil
+}
+
+// Reminders set outside of guilds are sent to the user by DM, since the bot may not be able to post in the channel
+func processDMReminder(task *database.ScheduledTask, ctx *TaskData) error {
+	var payload database.ScheduledTaskReminderPayload
+	if err := json.Unmarshal(task.Payload, &payload); err != nil {
+		return fmt.Errorf("failed to parse scheduled task payload: %w", err)
+	}
+	bot, ok := ctx.BotManager.GetApplicationBot(payload.ApplicationID)
+	if !ok {
+		return fmt.Errorf("no bot for application %s", payload.ApplicationID)
+	}
+	channel, err := bot.UserChannelCreate(task.UserID)
+	if err != nil {
+		return fmt.Errorf("failed to open DM channel with %s: %w", task.UserID, err)
+	}
+	text := i18n.Get(dg.Locale(payload.Locale), "reminder/notif", &i18n.Vars{"name": "<@" + task.UserID + ">", "content": payload.Reason})
+	if _, err := bot.ChannelMessageSendComplex(channel.ID, &dg.MessageSend{
+		Content:         text,
+		AllowedMentions: &dg.MessageAllowedMentions{Parse: []dg.AllowedMentionType{dg.AllowedMentionTypeUsers}},
+	}); err != nil {
+		return fmt.Errorf("failed to send reminder message: %w", err)
 	}
 	return nil
 }
 
 func processBirthday(task *database.ScheduledTask, ctx *TaskData) error {
-	bot, guild, member := _getTaskInfo(task, ctx)
-	if bot == nil || guild == nil || member == nil {
-		return nil
+	bot, guild, member, err := _getTaskInfo(task, ctx)
+	if err != nil {
+		return err
 	}
 	var payload database.ScheduledTaskBirthdayPayload
 	if err := json.Unmarshal(task.Payload, &payload); err != nil {
-		ctx.Logger.Error().Err(err).Msg("Failed to parse scheduled task payload")
-		return nil
+		return fmt.Errorf("failed to parse scheduled task payload: %w", err)
 	}
 	channelId, err := config.ProfileBirthdayChannel.Get(task.GuildID).Value()
 	if err != nil {
-		ctx.Logger.Error().Err(err).Msg("Failed to get birthday channel")
-		return nil
+		return fmt.Errorf("failed to get birthday channel: %w", err)
 	}
 	text := i18n.Get(dg.Locale(guild.PreferredLocale), "my/birthday/notif", &i18n.Vars{"name": member.Mention()})
 	_, err = bot.ChannelMessageSendComplex(string(channelId), &dg.MessageSend{
@@ -99,34 +129,30 @@ func processBirthday(task *database.ScheduledTask, ctx *TaskData) error {
 		AllowedMentions: &dg.MessageAllowedMentions{Parse: []dg.AllowedMentionType{dg.AllowedMentionTypeUsers}},
 	})
 	if err != nil {
-		ctx.Logger.Error().Err(err).Msg("Failed to send birthday message")
-		return nil
+		return fmt.Errorf("failed to send birthday message: %w", err)
 	}
 
 	// schedule the next birthday
 	nextBirthday := task.ProcessAfter.AddDate(1, 0, 0)
-	database.Database.Create(&database.ScheduledTask{
+	return database.Database.Create(&database.ScheduledTask{
 		GuildID:      task.GuildID,
 		TaskType:     database.TaskTypeBirthday,
 		ProcessAfter: nextBirthday,
 		UserID:       task.UserID,
-	})
-	return nil
+	}).Error
 }
 
 func processRemoveRole(task *database.ScheduledTask, ctx *TaskData) error {
-	bot, guild, member := _getTaskInfo(task, ctx)
-	if bot == nil || guild == nil || member == nil {
-		return nil
+	bot, guild, member, err := _getTaskInfo(task, ctx)
+	if err != nil {
+		return err
 	}
 	var payload database.ScheduledTaskRemoveRolePayload
 	if err := json.Unmarshal(task.Payload, &payload); err != nil {
-		ctx.Logger.Error().Err(err).Msg("Failed to parse scheduled task payload")
-		return nil
+		return fmt.Errorf("failed to parse scheduled task payload: %w", err)
 	}
 	if err := bot.GuildMemberRoleRemove(guild.ID, member.User.ID, payload.RoleID); err != nil {
-		ctx.Logger.Error().Err(err).Msg("Failed to remove role")
-		return err
+		return fmt.Errorf("failed to remove role %s: %w", payload.RoleID, err)
 	}
 	ctx.Logger.Info().Str("guild", guild.ID).Str("user", member.User.ID).Str("role", payload.RoleID).Msg("Removed temporary role")
 	return nil
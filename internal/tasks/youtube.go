@@ -2,120 +2,140 @@ package tasks
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"os"
 	"snoozybot/internal/config"
 	"snoozybot/internal/i18n"
+	"snoozybot/internal/metrics"
+	"sync"
 	"text/template"
 	"time"
 
+	dg "github.com/bwmarrin/discordgo"
 	"github.com/samber/lo"
 	"google.golang.org/api/option"
 	"google.golang.org/api/youtube/v3"
 )
 
-var yt *youtube.PlaylistItemsService
+// Created on the first run of the task, so the package can be loaded without a Youtube API key
+var playlistItems = sync.OnceValues(func() (*youtube.PlaylistItemsService, error) {
+	apiKey, ok := os.LookupEnv("YOUTUBE_API_KEY")
+	if !ok {
+		return nil, errors.New("YOUTUBE_API_KEY is not set")
+	}
+	service, err := youtube.NewService(context.Background(), option.WithAPIKey(apiKey))
+	if err != nil {
+		return nil, err
+	}
+	return youtube.NewPlaylistItemsService(service), nil
+})
 var lastPublishedMap = make(map[string]time.Time) // youtube channel id -> last publishedAt
 
-func init() {
-	apiKey := lo.Must(os.LookupEnv("YOUTUBE_API_KEY"))
-	yt = youtube.NewPlaylistItemsService(lo.Must(youtube.NewService(context.Background(), option.WithAPIKey(apiKey))))
-}
-
 var youtubeNotificationTask = PeriodicTask{
 	Name:     "youtubeNotificationTask",
 	Interval: 15 * time.Minute,
+	Intents:  dg.IntentsNone, // only uses the REST API
 	TaskHandler: func(ctx *TaskData) error {
+		yt, err := playlistItems()
+		if err != nil {
+			return err
+		}
 		ctx.Logger.Info().Msg("Checking for new Youtube videos.")
 		guildPlaylists := config.YoutubeNotifPlaylistIDs.GetAll()
 		guildChannels := config.YoutubeNotifChannelID.GetAll()
 		templates := config.YoutubeNotifTemplate.GetAll()
 		for guildId, playlist := range guildPlaylists {
-			// Validate as much as possible before doing any youtube queries. Youtube has pretty low daily quotas.
-			channelIdConfig, ok := guildChannels[guildId]
-			if !ok {
-				ctx.Logger.Error().Str("guild_id", guildId).Msg("No discord channel configured for youtube notifications.")
-				continue
-			}
-			channelId, err := channelIdConfig.Value()
-			if err != nil {
-				ctx.Logger.Error().Err(err).Str("guild_id", guildId).Msg("Failed to parse discord channel id.")
-				continue
-			}
-			templateConfig := templates[guildId]
-			if templateConfig == nil {
-				ctx.Logger.Error().Str("guild_id", guildId).Msg("No template configured for youtube notifications.")
-				continue
-			}
-			templateStr, err := templateConfig.Value()
-			if err != nil {
-				ctx.Logger.Error().Err(err).Str("guild_id", guildId).Msg("Failed to get youtube notification template.")
-				continue
-			}
-			tmpl, err := template.New("youtube_notification").Parse(templateStr)
-			if err != nil {
-				ctx.Logger.Error().Err(err).Str("guild_id", guildId).Msg("Failed to parse youtube notification template.")
-				continue
-			}
-			bot, ok := ctx.BotManager.GuildBots[guildId]
-			if !ok {
-				ctx.Logger.Error().Str("guild_id", guildId).Msg("No bot found for guild.")
-				continue
-			}
-			playlistIds, err := playlist.Value()
-			if err != nil {
-				ctx.Logger.Error().Err(err).Str("guild_id", guildId).Msg("Failed to get Youtube Channel IDs")
-				continue
-			}
-			for _, playlist := range playlistIds {
-
-				// Get youtube videos
-				ctx.Logger.Debug().Str("guild_id", guildId).Str("playlist", playlist).Msg("Checking for new Youtube videos.")
-
-				videos, err := yt.List([]string{"snippet", "contentDetails", "status"}).PlaylistId(playlist).MaxResults(5).Do()
+			ctx.ForGuild(guildId, func() error {
+				// Validate as much as possible before doing any youtube queries. Youtube has pretty low daily quotas.
+				channelIdConfig, ok := guildChannels[guildId]
+				if !ok {
+					return errors.New("no discord channel configured for youtube notifications")
+				}
+				channelId, err := channelIdConfig.Value()
 				if err != nil {
-					ctx.Logger.Error().Err(err).Str("guild_id", guildId).Str("channel_id", playlist).Msg("Failed to get Youtube videos")
-					continue
+					return fmt.Errorf("failed to parse discord channel id: %w", err)
 				}
-				lastKnownPublishedAt := lastPublishedMap[playlist]
-
-				// Find first video (latest chronologically) that is public
-				publicVideos := lo.Filter(videos.Items, func(video *youtube.PlaylistItem, _ int) bool {
-					return video.Status.PrivacyStatus == "public"
-				})
-				if len(publicVideos) == 0 {
-					ctx.Logger.Warn().Str("guild_id", guildId).Str("youtube_channel_id", playlist).Msg("Youtube query returned no videos.")
-					continue
+				templateConfig := templates[guildId]
+				if templateConfig == nil {
+					return errors.New("no template configured for youtube notifications")
+				}
+				templateStr, err := templateConfig.Value()
+				if err != nil {
+					return fmt.Errorf("failed to get youtube notification template: %w", err)
+				}
+				tmpl, err := template.New("youtube_notification").Parse(templateStr)
+				if err != nil {
+					return fmt.Errorf("failed to parse youtube notification template: %w", err)
 				}
-				latestVideoPublishedAt, err := time.Parse(time.RFC3339, publicVideos[0].Snippet.PublishedAt)
+				bot, ok := ctx.BotManager.GetBot(guildId)
+				if !ok {
+					return fmt.Errorf("no bot for guild %s", guildId)
+				}
+				playlistIds, err := playlist.Value()
 				if err != nil {
-					ctx.Logger.Error().Err(err).Str("guild_id", guildId).Any("data", videos.Items).Msg("Failed to parse Youtube video publishedAt time.")
-					continue
+					return fmt.Errorf("failed to get youtube playlist IDs: %w", err)
 				}
-				if lastKnownPublishedAt.IsZero() {
-					// Bot first start. Assume the latest video is the last known.
-					ctx.Logger.Info().Str("guild_id", guildId).Str("youtube_channel_id", playlist).Time("latest_video_published_at", latestVideoPublishedAt).Msg("First loading youtube videos for channel.")
-					lastPublishedMap[playlist] = latestVideoPublishedAt
-					continue
-				} else if latestVideoPublishedAt.After(lastKnownPublishedAt) {
-					// There are new videos!
-					newVideos := lo.Filter(publicVideos, func(video *youtube.PlaylistItem, _ int) bool {
-						t, err := time.Parse(time.RFC3339, video.Snippet.PublishedAt)
-						return err == nil && t.After(lastKnownPublishedAt)
+				for _, playlist := range playlistIds {
+					ctx.ForGuild(guildId, func() error {
+						return checkYoutubePlaylist(ctx, yt, bot, guildId, string(channelId), tmpl, playlist)
 					})
-					lastPublishedMap[playlist] = latestVideoPublishedAt
-					ctx.Logger.Info().Str("guild_id", guildId).Str("youtube_channel_id", playlist).Any("videos", newVideos).Msg("New Youtube videos found, sending notifications")
-
-					// Send notifications
-					for _, video := range newVideos {
-						message := i18n.TemplateString(tmpl, &i18n.Vars{"url": "https://www.youtube.com/watch?v=" + video.ContentDetails.VideoId, "channel": video.Snippet.ChannelTitle})
-						_, err := bot.ChannelMessageSend(string(channelId), message)
-						if err != nil {
-							ctx.Logger.Error().Err(err).Str("guild_id", guildId).Str("channel_id", string(channelId)).Str("text", message).Msg("Failed to send notification message in Discord.")
-						}
-					}
 				}
-			}
+				return nil
+			})
 		}
 		return nil
 	},
 }
+
+// Sends a notification for each video added to a playlist since the last check
+func checkYoutubePlaylist(ctx *TaskData, yt *youtube.PlaylistItemsService, bot *dg.Session, guildId string, channelId string, tmpl *template.Template, playlist string) error {
+	// Get youtube videos
+	ctx.Logger.Debug().Str("guild_id", guildId).Str("playlist", playlist).Msg("Checking for new Youtube videos.")
+
+	start := time.Now()
+	videos, err := yt.List([]string{"snippet", "contentDetails", "status"}).PlaylistId(playlist).MaxResults(5).Do()
+	metrics.ObserveAPI("youtube", "playlistItems.list", start, err)
+	if err != nil {
+		return fmt.Errorf("failed to get youtube videos of %s: %w", playlist, err)
+	}
+	lastKnownPublishedAt := lastPublishedMap[playlist]
+
+	// Find first video (latest chronologically) that is public
+	publicVideos := lo.Filter(videos.Items, func(video *youtube.PlaylistItem, _ int) bool {
+		return video.Status.PrivacyStatus == "public"
+	})
+	if len(publicVideos) == 0 {
+		ctx.Logger.Warn().Str("guild_id", guildId).Str("youtube_channel_id", playlist).Msg("Youtube query returned no videos.")
+		return nil
+	}
+	latestVideoPublishedAt, err := time.Parse(time.RFC3339, publicVideos[0].Snippet.PublishedAt)
+	if err != nil {
+		return fmt.Errorf("failed to parse youtube video publishedAt time: %w", err)
+	}
+	if lastKnownPublishedAt.IsZero() {
+		// Bot first start. Assume the latest video is the last known.
+		ctx.Logger.Info().Str("guild_id", guildId).Str("youtube_channel_id", playlist).Time("latest_video_published_at", latestVideoPublishedAt).Msg("First loading youtube videos for channel.")
+		lastPublishedMap[playlist] = latestVideoPublishedAt
+		return nil
+	} else if !latestVideoPublishedAt.After(lastKnownPublishedAt) {
+		return nil
+	}
+	// There are new videos!
+	newVideos := lo.Filter(publicVideos, func(video *youtube.PlaylistItem, _ int) bool {
+		t, err := time.Parse(time.RFC3339, video.Snippet.PublishedAt)
+		return err == nil && t.After(lastKnownPublishedAt)
+	})
+	lastPublishedMap[playlist] = latestVideoPublishedAt
+	ctx.Logger.Info().Str("guild_id", guildId).Str("youtube_channel_id", playlist).Any("videos", newVideos).Msg("New Youtube videos found, sending notifications")
+
+	// Send notifications
+	var errs []error
+	for _, video := range newVideos {
+		message := i18n.TemplateString(tmpl, &i18n.Vars{"url": "https://www.youtube.com/watch?v=" + video.ContentDetails.VideoId, "channel": video.Snippet.ChannelTitle})
+		if _, err := bot.ChannelMessageSend(channelId, message); err != nil {
+			errs = append(errs, fmt.Errorf("failed to send notification message in channel %s: %w", channelId, err))
+		}
+	}
+	return errors.Join(errs...)
+}
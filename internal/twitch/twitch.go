@@ -1,8 +1,13 @@
 package twitch
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"os"
+	"snoozybot/internal/background"
+	"snoozybot/internal/metrics"
+	"sync"
 	"time"
 
 	"github.com/hashicorp/golang-lru/v2/expirable"
@@ -17,10 +22,13 @@ type CachedStream struct {
 	IsNew bool
 }
 
-var client = lo.Must(helix.NewClient(&helix.Options{
-	ClientID:     lo.Must(os.LookupEnv("TWITCH_CLIENT_ID")),
-	ClientSecret: lo.Must(os.LookupEnv("TWITCH_CLIENT_SECRET")),
-}))
+// Created on first use, so the package can be loaded without Twitch credentials, e.g. to replay handlers in tests
+var getClient = sync.OnceValues(func() (*helix.Client, error) {
+	return helix.NewClient(&helix.Options{
+		ClientID:     os.Getenv("TWITCH_CLIENT_ID"),
+		ClientSecret: os.Getenv("TWITCH_CLIENT_SECRET"),
+	})
+})
 
 var lastKnownStreamIDs = make(map[string]string) // login -> streamID
 
@@ -31,24 +39,57 @@ func withTokenRefresh[T any](fn func() (response T, code int, err error)) (T, er
 	resp, code, err := fn()
 	if err == nil && code == 401 {
 		log.Info().Msg("Twitch returned 401. Refreshing app access token.")
-		token, err := client.RequestAppAccessToken([]string{})
-		if err != nil {
+		if err := refreshAppAccessToken(); err != nil {
 			return resp, err
 		}
-		client.SetAppAccessToken(token.Data.AccessToken)
 		resp, _, err = fn()
 		return resp, err
 	}
 	return resp, err
 }
 
+// Records helix calls in the API metrics. Helix reports HTTP errors in the response rather than as an error.
+func observe(operation string, start time.Time, code int, err error) {
+	if err == nil && code >= 400 {
+		err = fmt.Errorf("twitch returned status %d", code)
+	}
+	metrics.ObserveAPI("twitch", operation, start, err)
+}
+
+func getStreams(params *helix.StreamsParams) (*helix.StreamsResponse, error) {
+	client, err := getClient()
+	if err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	resp, err := client.GetStreams(params)
+	observe("GetStreams", start, lo.TernaryF(resp == nil, lo.Empty[int], func() int { return resp.StatusCode }), err)
+	return resp, err
+}
+
+// Requests a new app access token and makes the client use it
+func refreshAppAccessToken() error {
+	client, err := getClient()
+	if err != nil {
+		return err
+	}
+	start := time.Now()
+	token, err := client.RequestAppAccessToken([]string{})
+	observe("RequestAppAccessToken", start, lo.TernaryF(token == nil, lo.Empty[int], func() int { return token.StatusCode }), err)
+	if err != nil {
+		return err
+	}
+	client.SetAppAccessToken(token.Data.AccessToken)
+	return nil
+}
+
 // Gets a list of streams from Twitch
 func GetStreams(logins []string) (map[string]helix.Stream, error) {
 	result := make(map[string]helix.Stream)
 	for _, chunk := range lo.Chunk(logins, 100) {
 		streams, err := withTokenRefresh(func() (*helix.StreamsResponse, int, error) {
-			resp, err := client.GetStreams(&helix.StreamsParams{UserLogins: chunk})
-			return resp, resp.StatusCode, err
+			resp, err := getStreams(&helix.StreamsParams{UserLogins: chunk})
+			return resp, lo.TernaryF(resp == nil, lo.Empty[int], func() int { return resp.StatusCode }), err
 		})
 		if err != nil {
 			return nil, err
@@ -67,30 +108,29 @@ func GetStreams(logins []string) (map[string]helix.Stream, error) {
 
 // Gets a single stream from Twitch. Repeated requests (from multiple servers) are cached.
 // A stream is not "new" if the user toggles streamer mode off and on, but did not stop and go live again on twitch.
-func AttemptGetStream(login string) (stream helix.Stream, isNew bool, err error) {
+// Retries for a few minutes since twitch takes a while to list new streams; stops early if ctx is cancelled.
+func AttemptGetStream(ctx context.Context, login string) (stream helix.Stream, isNew bool, err error) {
 	strm, ok := cache.Get(login)
 	if ok {
 		log.Debug().Str("login", login).Any("stream", strm).Msg("Got stream from cache")
 		return strm.Stream, strm.IsNew, nil
 	}
 
-	_, _, err = lo.AttemptWithDelay(12, 15*time.Second, func(index int, duration time.Duration) error {
+	err = background.Retry(ctx, 12, 15*time.Second, func(index int) error {
 		streams, err := withTokenRefresh(func() (*helix.StreamsResponse, int, error) {
-			resp, err := client.GetStreams(&helix.StreamsParams{UserLogins: []string{login}})
-			return resp, resp.StatusCode, err
+			resp, err := getStreams(&helix.StreamsParams{UserLogins: []string{login}})
+			return resp, lo.TernaryF(resp == nil, lo.Empty[int], func() int { return resp.StatusCode }), err
 		})
 		if err != nil {
 			return err
 		} else if streams.Error != "" {
 			if streams.StatusCode == 401 {
-				token, err := client.RequestAppAccessToken([]string{})
-				if err != nil {
+				if err := refreshAppAccessToken(); err != nil {
 					log.Error().Err(err).Msg("Failed to refresh Twitch app access token")
 					return err
 				}
-				client.SetAppAccessToken(token.Data.AccessToken)
 				// Retry immediately with new token
-				streams, err = client.GetStreams(&helix.StreamsParams{UserLogins: []string{login}})
+				streams, err = getStreams(&helix.StreamsParams{UserLogins: []string{login}})
 				if err != nil {
 					return err
 				}
@@ -124,7 +164,13 @@ func AttemptGetStream(login string) (stream helix.Stream, isNew bool, err error)
 }
 
 func GetProfileImageURL(login string) (string, error) {
+	client, err := getClient()
+	if err != nil {
+		return "", err
+	}
+	start := time.Now()
 	user, err := client.GetUsers(&helix.UsersParams{Logins: []string{login}})
+	observe("GetUsers", start, lo.TernaryF(user == nil, lo.Empty[int], func() int { return user.StatusCode }), err)
 	if err != nil {
 		return "", err
 	}
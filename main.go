@@ -5,25 +5,63 @@ import (
 
 	"os"
 	"os/signal"
+	"syscall"
+	"time"
 
+	"snoozybot/internal/background"
 	"snoozybot/internal/bot"
+	"snoozybot/internal/database"
+	"snoozybot/internal/status"
 
 	"github.com/rs/zerolog/log"
 )
 
+// Together with the grace period after cancelling, shutdown fits in the 10s docker stop waits by default, leaving
+// time to close the gateway sessions
+const defaultShutdownTimeout = 8*time.Second - background.CancelGracePeriod
+
 func main() {
+	if len(os.Args) > 1 {
+		os.Exit(runCommand(os.Args[1:]))
+	}
 	log.Info().Msg("Hello from Snoozybot!")
+	if err := database.Connect(); err != nil {
+		log.Fatal().Err(err).Msg("Failed to connect to the database.")
+	}
+
+	botManager := bot.CreateBotManager(taskManager.IntentRequirements()...)
+
+	// Optional status server for supervisors; it reports not ready until the bots and tasks below have started
+	if addr := os.Getenv("STATUS_ADDR"); addr != "" {
+		statusServer := (&status.Server{Bots: botManager, Tasks: taskManager}).Serve(addr)
+		defer statusServer.Close()
+	}
 
-	botManager := bot.CreateBotManager()
 	botManager.Start()
 	taskManager.Start(botManager)
 
-	// Wait for interrupt
+	// Wait for interrupt or termination (e.g. docker stop, systemd)
 	sigch := make(chan os.Signal, 1)
-	signal.Notify(sigch, os.Interrupt)
-	<-sigch
+	signal.Notify(sigch, os.Interrupt, syscall.SIGTERM)
+	sig := <-sigch
 
-	log.Info().Msg("Interrupt Received. Stopping all processes.")
+	// Stop scheduling tasks, let running handlers and goroutines finish (or cancel them once the deadline passes),
+	// then close the sessions they use.
+	log.Info().Str("signal", sig.String()).Msg("Signal received. Stopping all processes.")
 	taskManager.Stop()
+	background.Shutdown(shutdownTimeout())
 	botManager.Stop()
+	log.Info().Msg("Shutdown complete.")
+}
+
+// How long shutdown waits for running work before cancelling it. Work then gets background.CancelGracePeriod to
+// return, so with a longer SHUTDOWN_TIMEOUT the supervisor's kill timeout must be raised to match.
+func shutdownTimeout() time.Duration {
+	if value := os.Getenv("SHUTDOWN_TIMEOUT"); value != "" {
+		if timeout, err := time.ParseDuration(value); err == nil {
+			return timeout
+		}
+		log.Warn().Str("value", value).Msg("Invalid SHUTDOWN_TIMEOUT. Using the default.")
+	}
+	return defaultShutdownTimeout
 }
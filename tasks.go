@@ -2,13 +2,20 @@ package main
 
 import (
 	"context"
+	"errors"
 	"runtime/debug"
+	"snoozybot/internal/background"
 	"snoozybot/internal/bot"
+	"snoozybot/internal/errorreport"
+	"snoozybot/internal/metrics"
+	"snoozybot/internal/status"
 	"snoozybot/internal/tasks"
 	"sync"
 	"time"
 
+	dg "github.com/bwmarrin/discordgo"
 	"github.com/rs/zerolog/log"
+	"github.com/samber/lo"
 )
 
 type TaskManager struct {
@@ -16,18 +23,21 @@ type TaskManager struct {
 	Ready      sync.WaitGroup
 	stop       chan struct{}
 	botManager *bot.BotManager
-	wg         sync.WaitGroup
+	statusMu   sync.RWMutex
+	status     map[string]*status.Task // task name -> run history
+	// Returns the session to report a guild's task errors with. The bot manager's GetBot, set by Start.
+	sessionFor func(guildID string) (*dg.Session, bool)
 }
 
 var taskManager = &TaskManager{
-	tasks: tasks.Tasks,
-	stop:  make(chan struct{}),
+	tasks:  tasks.Tasks,
+	stop:   make(chan struct{}),
+	status: make(map[string]*status.Task),
 }
 
-var globalCtx, cancelGlobalCtx = context.WithCancel(context.Background())
-
 func (tm *TaskManager) Start(botManager *bot.BotManager) {
 	tm.botManager = botManager
+	tm.sessionFor = botManager.GetBot
 
 	log.Info().Msg("Waiting for all bots to be ready before starting tasks...")
 	tm.Ready.Wait()
@@ -36,43 +46,124 @@ func (tm *TaskManager) Start(botManager *bot.BotManager) {
 	for _, task := range tm.tasks {
 		log.Info().Str("task", task.Name).Msg("Starting periodic task")
 		ticker := time.NewTicker(task.Interval)
-		tm.wg.Add(1)
-		go func(task *tasks.PeriodicTask, ticker *time.Ticker) {
+		background.Go("task:"+task.Name, func(ctx context.Context) {
 			defer func() {
-				if rec := recover(); rec != nil {
-					log.Error().Str("task", task.Name).Err(rec.(error)).Bytes("stack", debug.Stack()).Msg("Panic captured during periodic task")
-				}
 				ticker.Stop()
 				log.Info().Str("task", task.Name).Msg("Stopped periodic task")
-				tm.wg.Done()
 			}()
-			exec := func() {
-				td := &tasks.TaskData{
-					BotManager: tm.botManager,
-					Logger:     log.With().Str("task", task.Name).Logger(),
-					Context:    context.WithoutCancel(globalCtx),
-				}
-				if err := task.TaskHandler(td); err != nil {
-					log.Error().Err(err).Msg("Error received during periodic task")
-				}
-			}
-
-			exec() // ticker runs at the end of the period
+			tm.run(ctx, task) // ticker runs at the end of the period
 			for {
 				select {
 				case <-tm.stop:
 					return
 				case <-ticker.C:
-					exec()
+					tm.run(ctx, task)
 				}
 			}
-		}(task, ticker)
+		})
+	}
+}
+
+// Runs a task once, recording the run and reporting its errors
+func (tm *TaskManager) run(ctx context.Context, task *tasks.PeriodicTask) {
+	td := &tasks.TaskData{
+		BotManager:  tm.botManager,
+		Logger:      log.With().Str("task", task.Name).Logger(),
+		Context:     ctx,
+		ReportError: func(err error, stack []byte) { tm.reportError(task, err, stack) },
+	}
+	start := time.Now()
+	var err error
+	var stack []byte
+	// a panic fails this run only; the task keeps running on its schedule
+	func() {
+		defer func() {
+			if rec := recover(); rec != nil {
+				err, stack = errorreport.PanicError(rec), debug.Stack()
+			}
+		}()
+		err = task.TaskHandler(td)
+	}()
+	tm.recordRun(task, start, err, stack != nil)
+	if err != nil {
+		tm.reportError(task, err, stack)
+	}
+}
+
+// Task errors are reported to the guild they concern, if the task marked them with errorreport.ForGuild, e.g. through
+// TaskData.ForGuild. Other task errors could reveal one guild's details to another, so they are only logged and
+// counted.
+func (tm *TaskManager) reportError(task *tasks.PeriodicTask, err error, stack []byte) {
+	id := errorreport.NewID()
+	if stack != nil {
+		log.Error().Str("task", task.Name).Str("id", id).Err(err).Bytes("stack", stack).Msg("Panic captured during periodic task")
+	} else {
+		log.Error().Str("task", task.Name).Str("id", id).Err(err).Msg("Error received during periodic task")
 	}
+	var guildErr *errorreport.GuildError
+	if !errors.As(err, &guildErr) {
+		metrics.TaskErrorsUnreported.WithLabelValues(task.Name).Inc()
+		return
+	}
+	s, ok := tm.sessionFor(guildErr.GuildID)
+	if !ok {
+		metrics.TaskErrorsUnreported.WithLabelValues(task.Name).Inc()
+		return
+	}
+	errorreport.Send(s, errorreport.Report{ID: id, Source: errorreport.SourceTask, Name: task.Name, GuildID: guildErr.GuildID, Err: guildErr.Err, Stack: stack})
+}
+
+func (tm *TaskManager) recordRun(task *tasks.PeriodicTask, start time.Time, err error, panicked bool) {
+	metrics.TaskDuration.WithLabelValues(task.Name).Observe(time.Since(start).Seconds())
+	metrics.TaskRuns.WithLabelValues(task.Name, lo.Ternary(panicked, metrics.OutcomePanic, metrics.Outcome(err))).Inc()
+
+	tm.statusMu.Lock()
+	defer tm.statusMu.Unlock()
+	st, ok := tm.status[task.Name]
+	if !ok {
+		st = &status.Task{Name: task.Name}
+		tm.status[task.Name] = st
+	}
+	st.Runs++
+	st.LastRun = &start
+	st.DurationMs = time.Since(start).Milliseconds()
+	st.LastError = ""
+	if err != nil {
+		st.LastError = err.Error()
+	}
+}
+
+// Reports the run history of every task, including those that have not run yet.
+func (tm *TaskManager) TaskStatus() []status.Task {
+	tm.statusMu.RLock()
+	defer tm.statusMu.RUnlock()
+	return lo.Map(tm.tasks, func(task *tasks.PeriodicTask, _ int) status.Task {
+		if st, ok := tm.status[task.Name]; ok {
+			return *st
+		}
+		return status.Task{Name: task.Name}
+	})
+}
+
+// Reports whether every task has completed at least one run.
+func (tm *TaskManager) AllTasksRan() bool {
+	tm.statusMu.RLock()
+	defer tm.statusMu.RUnlock()
+	return lo.EveryBy(tm.tasks, func(task *tasks.PeriodicTask) bool {
+		_, ok := tm.status[task.Name]
+		return ok
+	})
+}
+
+// Returns the gateway intents each task relies on, so the bot manager can request them.
+func (tm *TaskManager) IntentRequirements() []bot.IntentRequirement {
+	return lo.Map(tm.tasks, func(task *tasks.PeriodicTask, _ int) bot.IntentRequirement {
+		return bot.IntentRequirement{Name: task.Name, Intents: task.Intents}
+	})
 }
 
+// Stops scheduling task runs. Runs in progress are waited for by background.Shutdown.
 func (tm *TaskManager) Stop() {
 	log.Info().Msg("Received stop signal. Stopping all tasks...")
-	cancelGlobalCtx()
 	close(tm.stop)
-	tm.wg.Wait()
 }
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"snoozybot/internal/config"
+	"snoozybot/internal/errorreport"
+	"snoozybot/internal/metrics"
+	"snoozybot/internal/replay"
+	"snoozybot/internal/status"
+	"snoozybot/internal/tasks"
+	"strconv"
+	"strings"
+	"testing"
+
+	dg "github.com/bwmarrin/discordgo"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestTaskErrorsReachReporter(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler func(guildID string) func(td *tasks.TaskData) error
+		report  bool
+	}{
+		{name: "guild error", report: true, handler: func(guildID string) func(td *tasks.TaskData) error {
+			return func(td *tasks.TaskData) error {
+				td.ForGuild(guildID, func() error { return errors.New("notification failed") })
+				return nil
+			}
+		}},
+		{name: "guild panic", report: true, handler: func(guildID string) func(td *tasks.TaskData) error {
+			return func(td *tasks.TaskData) error {
+				td.ForGuild(guildID, func() error { panic("notification failed") })
+				return nil
+			}
+		}},
+		{name: "returned guild error", report: true, handler: func(guildID string) func(td *tasks.TaskData) error {
+			return func(td *tasks.TaskData) error {
+				return errorreport.ForGuild(guildID, errors.New("notification failed"))
+			}
+		}},
+		{name: "error without guild", handler: func(string) func(td *tasks.TaskData) error {
+			return func(td *tasks.TaskData) error { return errors.New("notification failed") }
+		}},
+		{name: "panic without guild", handler: func(string) func(td *tasks.TaskData) error {
+			return func(td *tasks.TaskData) error { panic("notification failed") }
+		}},
+	}
+	for i, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// a guild per test, since reports are rate limited per guild and task
+			guildID := "20000000000000000" + strconv.Itoa(i)
+			errorsChannelID := "30000000000000000" + strconv.Itoa(i)
+			config.ErrorsChannelID.Seed(guildID, json.Number(errorsChannelID))
+			session := replay.NewSession()
+			task := &tasks.PeriodicTask{Name: "failingTask", TaskHandler: test.handler(guildID)}
+			tm := &TaskManager{
+				tasks:      []*tasks.PeriodicTask{task},
+				status:     make(map[string]*status.Task),
+				sessionFor: func(id string) (*dg.Session, bool) { return session.Session, id == guildID },
+			}
+			unreported := testutil.ToFloat64(metrics.TaskErrorsUnreported.WithLabelValues(task.Name))
+
+			tm.run(context.Background(), task)
+
+			calls := session.API.Calls("POST /channels/" + errorsChannelID + "/messages")
+			if !test.report {
+				if len(calls) != 0 {
+					t.Fatalf("sent %d reports, want none", len(calls))
+				}
+				if got := testutil.ToFloat64(metrics.TaskErrorsUnreported.WithLabelValues(task.Name)); got != unreported+1 {
+					t.Errorf("unreported task errors went from %v to %v, want one more", unreported, got)
+				}
+				return
+			}
+			if len(calls) != 1 {
+				t.Fatalf("sent %d reports, want 1", len(calls))
+			}
+			var message dg.MessageSend
+			if err := calls[0].Decode(&message); err != nil {
+				t.Fatal(err)
+			}
+			if len(message.Embeds) != 1 || !strings.Contains(embedText(message.Embeds[0]), "notification failed") {
+				t.Errorf("report %+v does not contain the error", message.Embeds)
+			}
+			if st := tm.TaskStatus()[0]; st.Runs != 1 {
+				t.Errorf("recorded %d runs, want 1", st.Runs)
+			}
+		})
+	}
+}
+
+func embedText(embed *dg.MessageEmbed) string {
+	text := embed.Title + "\n" + embed.Description
+	for _, field := range embed.Fields {
+		text += "\n" + field.Name + ": " + field.Value
+	}
+	return text
+}